@@ -1,7 +1,17 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/Trendyol/go-dcp/models"
+	"github.com/Trendyol/go-dcp/wrapper"
 
 	dcp "github.com/Trendyol/go-dcp/config"
 
@@ -10,6 +20,7 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/pprof"
 
 	"github.com/Trendyol/go-dcp/couchbase"
+	"github.com/Trendyol/go-dcp/helpers"
 	"github.com/Trendyol/go-dcp/logger"
 	"github.com/Trendyol/go-dcp/servicediscovery"
 	"github.com/Trendyol/go-dcp/stream"
@@ -17,8 +28,13 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
+const _leaderInfoTimeout = time.Second
+
+var ErrPortInUse = errors.New("api port is in use")
+
 type API interface {
-	Listen()
+	// Listen returns an error only if the api cannot start and api.portInUsePolicy is fail
+	Listen() error
 	Shutdown()
 }
 
@@ -26,20 +42,60 @@ type api struct {
 	client           couchbase.Client
 	stream           stream.Stream
 	serviceDiscovery servicediscovery.ServiceDiscovery
+	vBucketDiscovery stream.VBucketDiscovery
+	lastErrors       *helpers.LastErrors
+	goroutines       *helpers.Goroutines
 	app              *fiber.App
 	config           *dcp.Dcp
 }
 
-func (s *api) Listen() {
-	logger.Log.Info("api starting on port %d", s.config.API.Port)
+func (s *api) Listen() error {
+	ln, err := s.listen()
+	if err != nil {
+		logger.Log.Error("api cannot start on port %d, err: %v", s.config.API.Port, err)
 
-	err := s.app.Listen(fmt.Sprintf(":%d", s.config.API.Port))
+		if s.config.API.PortInUsePolicy == dcp.APIPortInUsePolicyFail {
+			return err
+		}
+
+		return nil
+	}
+
+	err = s.app.Listener(ln)
 
 	if err != nil {
-		logger.Log.Error("api cannot start on port %d, err: %v", s.config.API.Port, err)
+		logger.Log.Error("api stopped with err: %v", err)
 	} else {
 		logger.Log.Info("api stopped")
 	}
+
+	return nil
+}
+
+// listen tries the next ports while they are in use if api.portInUsePolicy is retryPort
+func (s *api) listen() (net.Listener, error) {
+	attempts := 1
+	if s.config.API.PortInUsePolicy == dcp.APIPortInUsePolicyRetryPort {
+		attempts += s.config.API.PortRetries
+	}
+
+	for i := 0; i < attempts; i++ {
+		port := s.config.API.Port + i
+
+		ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+		if err == nil {
+			logger.Log.Info("api starting on port %d", port)
+			return ln, nil
+		}
+
+		if !errors.Is(err, syscall.EADDRINUSE) {
+			return nil, err
+		}
+
+		logger.Log.Warn("api port %d is in use", port)
+	}
+
+	return nil, fmt.Errorf("%w, tried %d ports from %d", ErrPortInUse, attempts, s.config.API.Port)
 }
 
 func (s *api) Shutdown() {
@@ -60,9 +116,49 @@ func (s *api) status(c *fiber.Ctx) error {
 
 func (s *api) offset(c *fiber.Ctx) error {
 	offsets, _, _ := s.stream.GetOffsets()
+
+	if c.Query("format") == "ranges" {
+		return c.JSON(collapseOffsetRanges(offsets))
+	}
+
 	return c.JSON(offsets)
 }
 
+// collapseOffsetRanges groups the contiguous vBuckets having the same seqNo like {"0-511": 0, "512": 12345}
+func collapseOffsetRanges(offsets *wrapper.ConcurrentSwissMap[uint16, *models.Offset]) map[string]uint64 {
+	seqNos := map[uint16]uint64{}
+	vbIds := make([]uint16, 0, offsets.Count())
+
+	offsets.Range(func(vbID uint16, offset *models.Offset) bool {
+		seqNos[vbID] = offset.SeqNo
+		vbIds = append(vbIds, vbID)
+		return true
+	})
+
+	sort.Slice(vbIds, func(i, j int) bool {
+		return vbIds[i] < vbIds[j]
+	})
+
+	ranges := map[string]uint64{}
+
+	for i := 0; i < len(vbIds); {
+		j := i
+		for j+1 < len(vbIds) && vbIds[j+1] == vbIds[j]+1 && seqNos[vbIds[j+1]] == seqNos[vbIds[i]] {
+			j++
+		}
+
+		key := strconv.Itoa(int(vbIds[i]))
+		if j > i {
+			key += "-" + strconv.Itoa(int(vbIds[j]))
+		}
+
+		ranges[key] = seqNos[vbIds[i]]
+		i = j + 1
+	}
+
+	return ranges
+}
+
 func (s *api) rebalance(c *fiber.Ctx) error {
 	s.stream.Rebalance()
 
@@ -77,11 +173,121 @@ func (s *api) followers(c *fiber.Ctx) error {
 	return c.JSON(s.serviceDiscovery.GetAll())
 }
 
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *api) cluster(c *fiber.Ctx) error {
+	clusterMembership, ok := s.vBucketDiscovery.GetMembership().(couchbase.ClusterMembership)
+	if !ok {
+		return c.Status(fiber.StatusNotImplemented).JSON(errorResponse{
+			Error: "cluster is only available for couchbase membership",
+		})
+	}
+
+	return c.JSON(clusterMembership.GetInstances())
+}
+
+func (s *api) assignment(c *fiber.Ctx) error {
+	return c.JSON(s.vBucketDiscovery.GetAssignment())
+}
+
+func (s *api) membership(c *fiber.Ctx) error {
+	return c.JSON(s.vBucketDiscovery.Explain())
+}
+
+type leaderState struct {
+	Leader bool `json:"leader"`
+}
+
+// leader is resolved by the leader election when it is enabled, otherwise the first member is the leader
+func (s *api) leader(c *fiber.Ctx) error {
+	if s.serviceDiscovery != nil {
+		return c.JSON(leaderState{Leader: s.serviceDiscovery.IsLeader()})
+	}
+
+	ctx, cancel := context.WithTimeout(c.UserContext(), _leaderInfoTimeout)
+	defer cancel()
+
+	info, err := s.vBucketDiscovery.GetMembership().GetInfoContext(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "membership info is not available yet")
+	}
+
+	return c.JSON(leaderState{Leader: info.MemberNumber == 1})
+}
+
+type rateLimit struct {
+	DocsPerSecond int `json:"docsPerSecond"`
+}
+
+func (s *api) getRateLimit(c *fiber.Ctx) error {
+	return c.JSON(rateLimit{DocsPerSecond: s.stream.GetRateLimiter().Limit()})
+}
+
+func (s *api) setRateLimit(c *fiber.Ctx) error {
+	var body rateLimit
+	if err := c.BodyParser(&body); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	if body.DocsPerSecond < 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "docsPerSecond must be non-negative")
+	}
+
+	s.stream.GetRateLimiter().SetLimit(body.DocsPerSecond)
+
+	logger.Log.Info("processing rate limit is set to %d docs/sec", body.DocsPerSecond)
+
+	return c.JSON(body)
+}
+
+func (s *api) errors(c *fiber.Ctx) error {
+	return c.JSON(s.lastErrors.Get())
+}
+
+func (s *api) connections(c *fiber.Ctx) error {
+	return c.JSON(s.client.GetDcpConnectionNames())
+}
+
+func (s *api) goroutineStates(c *fiber.Ctx) error {
+	return c.JSON(s.goroutines.Get())
+}
+
+func (s *api) registerRoutes() {
+	if s.config.Debug {
+		s.app.Use(pprof.New())
+		s.app.Get("/states/offset", s.offset)
+		s.app.Get("/states/followers", s.followers)
+		s.app.Get("/states/cluster", s.cluster)
+		s.app.Get("/states/assignment", s.assignment)
+		s.app.Get("/states/membership", s.membership)
+		s.app.Get("/states/goroutines", s.goroutineStates)
+	}
+
+	if !s.config.HealthCheck.Disabled {
+		s.app.Get("/status", s.status)
+	}
+
+	s.app.Get("/rebalance", s.rebalance)
+	s.app.Get("/states/errors", s.errors)
+	s.app.Get("/states/leader", s.leader)
+	s.app.Get("/states/connections", s.connections)
+	s.app.Get("/processing/ratelimit", s.getRateLimit)
+
+	// the rate limit is changed at runtime only when it is allowed explicitly
+	if s.config.API.AllowRateLimitUpdate {
+		s.app.Put("/processing/ratelimit", s.setRateLimit)
+	}
+}
+
 func NewAPI(config *dcp.Dcp,
 	client couchbase.Client,
 	stream stream.Stream,
 	serviceDiscovery servicediscovery.ServiceDiscovery,
 	vBucketDiscovery stream.VBucketDiscovery,
+	lastErrors *helpers.LastErrors,
+	goroutines *helpers.Goroutines,
 	metricCollectors ...prometheus.Collector,
 ) API {
 	app := fiber.New(fiber.Config{DisableStartupMessage: true})
@@ -92,6 +298,9 @@ func NewAPI(config *dcp.Dcp,
 		client:           client,
 		stream:           stream,
 		serviceDiscovery: serviceDiscovery,
+		vBucketDiscovery: vBucketDiscovery,
+		lastErrors:       lastErrors,
+		goroutines:       goroutines,
 	}
 
 	metricMiddleware, err := NewMetricMiddleware(app, config, stream, client, vBucketDiscovery, metricCollectors...)
@@ -102,17 +311,7 @@ func NewAPI(config *dcp.Dcp,
 		logger.Log.Error("metric middleware cannot be initialized: %v", err)
 	}
 
-	if config.Debug {
-		app.Use(pprof.New())
-		app.Get("/states/offset", api.offset)
-		app.Get("/states/followers", api.followers)
-	}
-
-	if !config.HealthCheck.Disabled {
-		app.Get("/status", api.status)
-	}
-
-	app.Get("/rebalance", api.rebalance)
+	api.registerRoutes()
 
 	return api
 }
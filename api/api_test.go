@@ -0,0 +1,483 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Trendyol/go-dcp/config"
+	"github.com/Trendyol/go-dcp/couchbase"
+	"github.com/Trendyol/go-dcp/helpers"
+	"github.com/Trendyol/go-dcp/logger"
+	"github.com/Trendyol/go-dcp/membership"
+	"github.com/Trendyol/go-dcp/models"
+	"github.com/Trendyol/go-dcp/servicediscovery"
+	"github.com/Trendyol/go-dcp/stream"
+	"github.com/Trendyol/go-dcp/wrapper"
+
+	"github.com/gofiber/fiber/v2"
+	jsoniter "github.com/json-iterator/go"
+)
+
+func TestAPI_ErrorsReturnsLastErrorsPerSubsystem(t *testing.T) {
+	lastErrors := helpers.NewLastErrors(10)
+	lastErrors.Add(models.MembershipSubsystem, errors.New("index not found"))
+	lastErrors.Add(models.CheckpointSubsystem, errors.New("checkpoint save timeout"))
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	api := &api{app: app, lastErrors: lastErrors}
+	app.Get("/states/errors", api.errors)
+
+	res, err := app.Test(httptest.NewRequest("GET", "/states/errors", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result map[string][]helpers.ErrorRecord
+	if err := jsoniter.NewDecoder(res.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result[models.MembershipSubsystem]) != 1 || result[models.MembershipSubsystem][0].Message != "index not found" {
+		t.Errorf("membership errors = %v", result[models.MembershipSubsystem])
+	}
+
+	if len(result[models.CheckpointSubsystem]) != 1 || result[models.CheckpointSubsystem][0].Message != "checkpoint save timeout" {
+		t.Errorf("checkpoint errors = %v", result[models.CheckpointSubsystem])
+	}
+
+	if _, ok := result[models.StreamSubsystem]; ok {
+		t.Errorf("stream should not have errors")
+	}
+}
+
+type fakeClusterMembership struct {
+	instances  []couchbase.Instance
+	collisions int64
+}
+
+func (m *fakeClusterMembership) GetInfo() *membership.Model {
+	return &membership.Model{MemberNumber: 1, TotalMembers: len(m.instances)}
+}
+
+func (m *fakeClusterMembership) GetInfoContext(_ context.Context) (*membership.Model, error) {
+	return m.GetInfo(), nil
+}
+
+func (m *fakeClusterMembership) Close() {
+}
+
+func (m *fakeClusterMembership) GetInstances() []couchbase.Instance {
+	return m.instances
+}
+
+func (m *fakeClusterMembership) GetMonitorTickDuration() *helpers.Histogram {
+	return helpers.NewHistogram([]float64{1})
+}
+
+func (m *fakeClusterMembership) GetMemberNumberCollisions() int64 {
+	return m.collisions
+}
+
+type fakeVBucketDiscovery struct {
+	membership membership.Membership
+}
+
+func (d *fakeVBucketDiscovery) Get() ([]uint16, error) {
+	return nil, nil
+}
+
+func (d *fakeVBucketDiscovery) Close() {
+}
+
+func (d *fakeVBucketDiscovery) GetMetric() *stream.VBucketDiscoveryMetric {
+	return &stream.VBucketDiscoveryMetric{}
+}
+
+func (d *fakeVBucketDiscovery) GetMembership() membership.Membership {
+	return d.membership
+}
+
+func (d *fakeVBucketDiscovery) GetAssignment() []stream.MemberAssignment {
+	return nil
+}
+
+func (d *fakeVBucketDiscovery) Explain() *stream.AssignmentExplanation {
+	return nil
+}
+
+func TestAPI_ClusterReturnsInstancesWithTags(t *testing.T) {
+	id := "instance-1"
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	api := &api{
+		app: app,
+		vBucketDiscovery: &fakeVBucketDiscovery{
+			membership: &fakeClusterMembership{
+				instances: []couchbase.Instance{{ID: &id, Type: "instance", Tags: map[string]string{"zone": "eu-west-1a"}}},
+			},
+		},
+	}
+	app.Get("/states/cluster", api.cluster)
+
+	res, err := app.Test(httptest.NewRequest("GET", "/states/cluster", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var instances []couchbase.Instance
+	if err := jsoniter.NewDecoder(res.Body).Decode(&instances); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(instances) != 1 || *instances[0].ID != id || instances[0].Tags["zone"] != "eu-west-1a" {
+		t.Errorf("instances = %v", instances)
+	}
+
+	// other memberships do not know the instances
+	api.vBucketDiscovery = &fakeVBucketDiscovery{membership: membership.NewStaticMembership(&config.Dcp{})}
+
+	res, err = app.Test(httptest.NewRequest("GET", "/states/cluster", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body errorResponse
+	if err := jsoniter.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.StatusCode != fiber.StatusNotImplemented || body.Error == "" {
+		t.Errorf("status = %v, body = %+v, want %v with an error", res.StatusCode, body, fiber.StatusNotImplemented)
+	}
+}
+
+func TestAPI_RateLimitAdjustsProcessingRate(t *testing.T) {
+	logger.InitDefaultLogger(logger.ERROR)
+
+	rateLimiter := helpers.NewRateLimiter(0)
+
+	newApp := func(allowUpdate bool) *fiber.App {
+		c := &config.Dcp{API: config.API{AllowRateLimitUpdate: allowUpdate}}
+		c.HealthCheck.Disabled = true
+
+		app := fiber.New(fiber.Config{DisableStartupMessage: true})
+		api := &api{app: app, config: c, stream: &stubStream{rateLimiter: rateLimiter}}
+		api.registerRoutes()
+
+		return app
+	}
+
+	app := newApp(true)
+
+	put := func(app *fiber.App, body string) int {
+		t.Helper()
+
+		req := httptest.NewRequest("PUT", "/processing/ratelimit", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return res.StatusCode
+	}
+
+	if status := put(newApp(false), `{"docsPerSecond": 100}`); status == fiber.StatusOK {
+		t.Errorf("rate limit is updated without allowRateLimitUpdate")
+	}
+
+	if limit := rateLimiter.Limit(); limit != 0 {
+		t.Fatalf("rate limit = %v, want 0", limit)
+	}
+
+	if status := put(app, `{"docsPerSecond": -1}`); status != fiber.StatusBadRequest {
+		t.Errorf("status = %v, want %v", status, fiber.StatusBadRequest)
+	}
+
+	if status := put(app, `{"docsPerSecond": 100}`); status != fiber.StatusOK {
+		t.Fatalf("status = %v, want %v", status, fiber.StatusOK)
+	}
+
+	res, err := app.Test(httptest.NewRequest("GET", "/processing/ratelimit", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result rateLimit
+	if err := jsoniter.NewDecoder(res.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+
+	if result.DocsPerSecond != 100 {
+		t.Errorf("docsPerSecond = %v, want 100", result.DocsPerSecond)
+	}
+
+	start := time.Now()
+
+	for i := 0; i < 10; i++ {
+		_ = rateLimiter.Wait(context.Background())
+	}
+
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("10 events at 100 docs/sec took %v, want at least 80ms", elapsed)
+	}
+}
+
+func TestAPI_OffsetCollapsesRanges(t *testing.T) {
+	offsets := wrapper.CreateConcurrentSwissMap[uint16, *models.Offset](1024)
+	for vbID := uint16(0); vbID < 512; vbID++ {
+		offsets.Store(vbID, &models.Offset{SeqNo: 0})
+	}
+	offsets.Store(512, &models.Offset{SeqNo: 12345})
+	offsets.Store(513, &models.Offset{SeqNo: 7})
+	offsets.Store(514, &models.Offset{SeqNo: 7})
+	offsets.Store(516, &models.Offset{SeqNo: 7})
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	api := &api{app: app, stream: &stubStream{offsets: offsets}}
+	app.Get("/states/offset", api.offset)
+
+	res, err := app.Test(httptest.NewRequest("GET", "/states/offset?format=ranges", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result map[string]uint64
+	if err := jsoniter.NewDecoder(res.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]uint64{"0-511": 0, "512": 12345, "513-514": 7, "516": 7}
+
+	if len(result) != len(expected) {
+		t.Fatalf("ranges = %v, want %v", result, expected)
+	}
+
+	for key, seqNo := range expected {
+		if actual, ok := result[key]; !ok || actual != seqNo {
+			t.Errorf("range %v = %v, want %v", key, actual, seqNo)
+		}
+	}
+}
+
+type staticMembership struct {
+	membership.Membership
+	memberNumber int
+}
+
+func (m *staticMembership) GetInfoContext(_ context.Context) (*membership.Model, error) {
+	return &membership.Model{MemberNumber: m.memberNumber, TotalMembers: 3}, nil
+}
+
+func TestAPI_LeaderReflectsMemberNumber(t *testing.T) {
+	for memberNumber, want := range map[int]bool{1: true, 2: false, 3: false} {
+		app := fiber.New(fiber.Config{DisableStartupMessage: true})
+		api := &api{
+			app:              app,
+			vBucketDiscovery: &fakeVBucketDiscovery{membership: &staticMembership{memberNumber: memberNumber}},
+		}
+		app.Get("/states/leader", api.leader)
+
+		res, err := app.Test(httptest.NewRequest("GET", "/states/leader", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var state leaderState
+		if err := jsoniter.NewDecoder(res.Body).Decode(&state); err != nil {
+			t.Fatal(err)
+		}
+
+		if state.Leader != want {
+			t.Errorf("member %v: leader = %v, want %v", memberNumber, state.Leader, want)
+		}
+	}
+}
+
+func TestAPI_LeaderFollowsLeaderElectionWhileItChanges(t *testing.T) {
+	c := &config.Dcp{}
+	c.ApplyDefaults()
+
+	sd := servicediscovery.NewServiceDiscovery(c, helpers.NewBus())
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	api := &api{app: app, serviceDiscovery: sd}
+	app.Get("/states/leader", api.leader)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			sd.BeLeader()
+			sd.DontBeLeader()
+		}
+	}()
+
+	for i := 0; i < 10; i++ {
+		if _, err := app.Test(httptest.NewRequest("GET", "/states/leader", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+
+	sd.BeLeader()
+
+	res, err := app.Test(httptest.NewRequest("GET", "/states/leader", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var state leaderState
+	if err := jsoniter.NewDecoder(res.Body).Decode(&state); err != nil {
+		t.Fatal(err)
+	}
+
+	if !state.Leader {
+		t.Errorf("leader = %v, want true after being elected", state.Leader)
+	}
+}
+
+func TestAPI_GoroutinesReflectsStoppedOnes(t *testing.T) {
+	goroutines := helpers.NewGoroutines()
+	heartbeat := goroutines.Start("membership-heartbeat")
+	goroutines.Start("stream-listen")
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	api := &api{app: app, goroutines: goroutines}
+	app.Get("/states/goroutines", api.goroutineStates)
+
+	get := func() map[string]helpers.GoroutineState {
+		t.Helper()
+
+		res, err := app.Test(httptest.NewRequest("GET", "/states/goroutines", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var states []helpers.GoroutineState
+		if err := jsoniter.NewDecoder(res.Body).Decode(&states); err != nil {
+			t.Fatal(err)
+		}
+
+		result := map[string]helpers.GoroutineState{}
+		for _, state := range states {
+			result[state.Name] = state
+		}
+
+		return result
+	}
+
+	states := get()
+	if len(states) != 2 || states["membership-heartbeat"].State != helpers.GoroutineRunning ||
+		states["stream-listen"].State != helpers.GoroutineRunning {
+		t.Fatalf("goroutines = %v, want both running", states)
+	}
+
+	if states["stream-listen"].LastActivity.IsZero() {
+		t.Errorf("last activity is not set")
+	}
+
+	heartbeat.Stop()
+
+	states = get()
+	if states["membership-heartbeat"].State != helpers.GoroutineStopped || states["stream-listen"].State != helpers.GoroutineRunning {
+		t.Errorf("goroutines = %v, want the heartbeat stopped", states)
+	}
+}
+
+func TestAPI_ListenAppliesPortInUsePolicy(t *testing.T) {
+	occupied, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer occupied.Close()
+
+	port := occupied.Addr().(*net.TCPAddr).Port
+
+	newAPI := func(policy string) *api {
+		c := &config.Dcp{
+			API:     config.API{Port: port, PortInUsePolicy: policy, PortRetries: 5},
+			Logging: config.Logging{Level: logger.ERROR},
+		}
+		c.ApplyDefaults()
+
+		return &api{app: fiber.New(fiber.Config{DisableStartupMessage: true}), config: c}
+	}
+
+	if err := newAPI(config.APIPortInUsePolicyIgnore).Listen(); err != nil {
+		t.Errorf("ignore policy err = %v, want nil", err)
+	}
+
+	if err := newAPI(config.APIPortInUsePolicyFail).Listen(); !errors.Is(err, ErrPortInUse) {
+		t.Errorf("fail policy err = %v, want %v", err, ErrPortInUse)
+	}
+
+	retrying := newAPI(config.APIPortInUsePolicyRetryPort)
+	retrying.app.Get("/ping", func(c *fiber.Ctx) error {
+		return c.SendString("pong")
+	})
+
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- retrying.Listen()
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for served := false; !served; {
+		if time.Now().After(deadline) {
+			t.Fatal("retry port policy does not serve on the next ports")
+		}
+
+		for next := port + 1; next <= port+5 && !served; next++ {
+			conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", next))
+			if err == nil {
+				conn.Close()
+				served = true
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	retrying.Shutdown()
+
+	if err := <-listenErr; err != nil {
+		t.Errorf("retry port policy err = %v, want nil", err)
+	}
+}
+
+type namedConnectionClient struct {
+	couchbase.Client
+	names []string
+}
+
+func (c *namedConnectionClient) GetDcpConnectionNames() []string {
+	return c.names
+}
+
+func TestAPI_ConnectionsReturnsDcpConnectionNames(t *testing.T) {
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	api := &api{app: app, client: &namedConnectionClient{names: []string{"group_0b1c9a7e"}}}
+	app.Get("/states/connections", api.connections)
+
+	res, err := app.Test(httptest.NewRequest("GET", "/states/connections", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	if err := jsoniter.NewDecoder(res.Body).Decode(&names); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(names) != 1 || names[0] != "group_0b1c9a7e" {
+		t.Errorf("connections = %v, want the dcp connection name", names)
+	}
+}
@@ -34,11 +34,18 @@ type metricCollector struct {
 	processLatency *prometheus.Desc
 	dcpLatency     *prometheus.Desc
 	rebalance      *prometheus.Desc
+	snapshotSize   *prometheus.Desc
+	dcpQueueDepth  *prometheus.Desc
+	backpressure   *prometheus.Desc
+	seqNoGap       *prometheus.Desc
+	caughtUp       *prometheus.Desc
 
 	lag *prometheus.Desc
 
 	totalMembers      *prometheus.Desc
 	memberNumber      *prometheus.Desc
+	memberCollisions  *prometheus.Desc
+	monitorTick       *prometheus.Desc
 	membershipType    *prometheus.Desc
 	vBucketCount      *prometheus.Desc
 	vBucketRangeStart *prometheus.Desc
@@ -46,6 +53,7 @@ type metricCollector struct {
 
 	offsetWrite        *prometheus.Desc
 	offsetWriteLatency *prometheus.Desc
+	checkpointLoad     *prometheus.Desc
 }
 
 func (s *metricCollector) Describe(ch chan<- *prometheus.Desc) {
@@ -59,6 +67,27 @@ func (s *metricCollector) Collect(ch chan<- prometheus.Metric) {
 		return
 	}
 
+	ch <- prometheus.MustNewConstMetric(
+		s.dcpQueueDepth,
+		prometheus.GaugeValue,
+		float64(observer.GetQueueDepth()),
+		[]string{}...,
+	)
+
+	ch <- prometheus.MustNewConstMetric(
+		s.backpressure,
+		prometheus.CounterValue,
+		float64(observer.GetBackpressureCount()),
+		[]string{}...,
+	)
+
+	ch <- prometheus.MustNewConstMetric(
+		s.seqNoGap,
+		prometheus.CounterValue,
+		float64(observer.GetSeqNoGapCount()),
+		[]string{}...,
+	)
+
 	seqNoMap, err := s.client.GetVBucketSeqNos()
 
 	observer.GetMetrics().Range(func(vbID uint16, metric *couchbase.ObserverMetric) bool {
@@ -138,14 +167,14 @@ func (s *metricCollector) Collect(ch chan<- prometheus.Metric) {
 	ch <- prometheus.MustNewConstMetric(
 		s.processLatency,
 		prometheus.GaugeValue,
-		float64(streamMetric.ProcessLatency),
+		float64(streamMetric.GetProcessLatency()),
 		[]string{}...,
 	)
 
 	ch <- prometheus.MustNewConstMetric(
 		s.dcpLatency,
 		prometheus.CounterValue,
-		float64(streamMetric.DcpLatency),
+		float64(streamMetric.GetDcpLatency()),
 		[]string{}...,
 	)
 
@@ -156,6 +185,28 @@ func (s *metricCollector) Collect(ch chan<- prometheus.Metric) {
 		[]string{}...,
 	)
 
+	var caughtUp float64
+	if streamMetric.CaughtUp.Load() {
+		caughtUp = 1
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		s.caughtUp,
+		prometheus.GaugeValue,
+		caughtUp,
+		[]string{}...,
+	)
+
+	snapshotCount, snapshotSum, snapshotBuckets := streamMetric.SnapshotSize.Snapshot()
+
+	ch <- prometheus.MustNewConstHistogram(
+		s.snapshotSize,
+		snapshotCount,
+		snapshotSum,
+		snapshotBuckets,
+		[]string{}...,
+	)
+
 	vBucketDiscoveryMetric := s.vBucketDiscovery.GetMetric()
 
 	ch <- prometheus.MustNewConstMetric(
@@ -179,6 +230,25 @@ func (s *metricCollector) Collect(ch chan<- prometheus.Metric) {
 		[]string{vBucketDiscoveryMetric.Type}...,
 	)
 
+	if clusterMembership, ok := s.vBucketDiscovery.GetMembership().(couchbase.ClusterMembership); ok {
+		ch <- prometheus.MustNewConstMetric(
+			s.memberCollisions,
+			prometheus.CounterValue,
+			float64(clusterMembership.GetMemberNumberCollisions()),
+			[]string{}...,
+		)
+
+		tickCount, tickSum, tickBuckets := clusterMembership.GetMonitorTickDuration().Snapshot()
+
+		ch <- prometheus.MustNewConstHistogram(
+			s.monitorTick,
+			tickCount,
+			tickSum,
+			tickBuckets,
+			[]string{}...,
+		)
+	}
+
 	ch <- prometheus.MustNewConstMetric(
 		s.vBucketCount,
 		prometheus.GaugeValue,
@@ -215,6 +285,13 @@ func (s *metricCollector) Collect(ch chan<- prometheus.Metric) {
 		float64(checkpointMetric.OffsetWriteLatency),
 		[]string{}...,
 	)
+
+	ch <- prometheus.MustNewConstMetric(
+		s.checkpointLoad,
+		prometheus.GaugeValue,
+		streamMetric.StartupCheckpointLoadSeconds,
+		[]string{}...,
+	)
 }
 
 //nolint:funlen
@@ -284,6 +361,36 @@ func newMetricCollector(client couchbase.Client, stream stream.Stream, vBucketDi
 			[]string{},
 			nil,
 		),
+		backpressure: prometheus.NewDesc(
+			prometheus.BuildFQName(helpers.Name, "dcp_backpressure", "total"),
+			"Received dcp messages that waited for a full listener buffer",
+			[]string{},
+			nil,
+		),
+		seqNoGap: prometheus.NewDesc(
+			prometheus.BuildFQName(helpers.Name, "dcp_seqno_gap", "total"),
+			"SeqNo gaps above the threshold within snapshots",
+			[]string{},
+			nil,
+		),
+		caughtUp: prometheus.NewDesc(
+			prometheus.BuildFQName(helpers.Name, "", "caught_up"),
+			"Whether all vBuckets reached the high seqNos observed at startup",
+			[]string{},
+			nil,
+		),
+		dcpQueueDepth: prometheus.NewDesc(
+			prometheus.BuildFQName(helpers.Name, "dcp_queue", "depth"),
+			"Received dcp messages waiting for the listener",
+			[]string{},
+			nil,
+		),
+		snapshotSize: prometheus.NewDesc(
+			prometheus.BuildFQName(helpers.Name, "snapshot", "size"),
+			"Snapshot size as end seq no - start seq no",
+			[]string{},
+			nil,
+		),
 		totalMembers: prometheus.NewDesc(
 			prometheus.BuildFQName(helpers.Name, "total_members", "current"),
 			"Total members",
@@ -296,6 +403,18 @@ func newMetricCollector(client couchbase.Client, stream stream.Stream, vBucketDi
 			[]string{},
 			nil,
 		),
+		memberCollisions: prometheus.NewDesc(
+			prometheus.BuildFQName(helpers.Name, "member_number_collision", "total"),
+			"Member number collisions",
+			[]string{},
+			nil,
+		),
+		monitorTick: prometheus.NewDesc(
+			prometheus.BuildFQName(helpers.Name, "monitor_tick_duration", "seconds"),
+			"Membership monitor tick duration in seconds",
+			[]string{},
+			nil,
+		),
 		membershipType: prometheus.NewDesc(
 			prometheus.BuildFQName(helpers.Name, "membership_type", "current"),
 			"Membership type",
@@ -332,6 +451,12 @@ func newMetricCollector(client couchbase.Client, stream stream.Stream, vBucketDi
 			[]string{},
 			nil,
 		),
+		checkpointLoad: prometheus.NewDesc(
+			prometheus.BuildFQName(helpers.Name, "startup", "checkpoint_load_seconds"),
+			"Startup checkpoint load duration in seconds",
+			[]string{},
+			nil,
+		),
 	}
 }
 
@@ -342,7 +467,13 @@ func NewMetricMiddleware(app *fiber.App,
 	vBucketDiscovery stream.VBucketDiscovery,
 	metricCollectors ...prometheus.Collector,
 ) (func(ctx *fiber.Ctx) error, error) {
-	prometheus.DefaultRegisterer.MustRegister(newMetricCollector(client, stream, vBucketDiscovery))
+	var collector prometheus.Collector = newMetricCollector(client, stream, vBucketDiscovery)
+
+	if config.Metric.CacheTTL > 0 {
+		collector = newCachedMetricCollector(collector, config.Metric.CacheTTL)
+	}
+
+	prometheus.DefaultRegisterer.MustRegister(collector)
 	prometheus.DefaultRegisterer.MustRegister(metricCollectors...)
 
 	fiberPrometheus := fiberprometheus.New(config.Dcp.Group.Name)
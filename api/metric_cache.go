@@ -0,0 +1,57 @@
+package api
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type cachedMetricCollector struct {
+	collector prometheus.Collector
+	expiresAt time.Time
+	metrics   []prometheus.Metric
+	ttl       time.Duration
+	lock      sync.Mutex
+}
+
+func (s *cachedMetricCollector) Describe(ch chan<- *prometheus.Desc) {
+	s.collector.Describe(ch)
+}
+
+func (s *cachedMetricCollector) Collect(ch chan<- prometheus.Metric) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if now := time.Now(); now.After(s.expiresAt) {
+		s.metrics = s.collect()
+		s.expiresAt = now.Add(s.ttl)
+	}
+
+	for _, metric := range s.metrics {
+		ch <- metric
+	}
+}
+
+func (s *cachedMetricCollector) collect() []prometheus.Metric {
+	metricCh := make(chan prometheus.Metric)
+
+	go func() {
+		s.collector.Collect(metricCh)
+		close(metricCh)
+	}()
+
+	var metrics []prometheus.Metric
+	for metric := range metricCh {
+		metrics = append(metrics, metric)
+	}
+
+	return metrics
+}
+
+func newCachedMetricCollector(collector prometheus.Collector, ttl time.Duration) prometheus.Collector {
+	return &cachedMetricCollector{
+		collector: collector,
+		ttl:       ttl,
+	}
+}
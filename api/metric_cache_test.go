@@ -0,0 +1,63 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type countingCollector struct {
+	desc    *prometheus.Desc
+	collect int
+}
+
+func (c *countingCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *countingCollector) Collect(ch chan<- prometheus.Metric) {
+	c.collect++
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(c.collect))
+}
+
+func TestCachedMetricCollector_ReusesMetricsWithinTTL(t *testing.T) {
+	collector := &countingCollector{
+		desc: prometheus.NewDesc("test_collect", "Collect count", nil, nil),
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newCachedMetricCollector(collector, time.Minute))
+
+	for i := 0; i < 2; i++ {
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if value := families[0].GetMetric()[0].GetGauge().GetValue(); value != 1 {
+			t.Errorf("scrape %v returned %v, want cached value 1", i+1, value)
+		}
+	}
+
+	if collector.collect != 1 {
+		t.Errorf("underlying collect ran %v times, want 1", collector.collect)
+	}
+}
+
+func TestCachedMetricCollector_CollectsAgainAfterTTL(t *testing.T) {
+	collector := &countingCollector{
+		desc: prometheus.NewDesc("test_collect", "Collect count", nil, nil),
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newCachedMetricCollector(collector, time.Millisecond))
+
+	_, _ = registry.Gather()
+	time.Sleep(5 * time.Millisecond)
+	_, _ = registry.Gather()
+
+	if collector.collect != 2 {
+		t.Errorf("underlying collect ran %v times, want 2", collector.collect)
+	}
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/Trendyol/go-dcp/couchbase"
+	"github.com/Trendyol/go-dcp/helpers"
+	"github.com/Trendyol/go-dcp/models"
+	"github.com/Trendyol/go-dcp/stream"
+	"github.com/Trendyol/go-dcp/wrapper"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type stubObserver struct {
+	couchbase.Observer
+	queueDepth int
+}
+
+func (o *stubObserver) GetMetrics() *wrapper.ConcurrentSwissMap[uint16, *couchbase.ObserverMetric] {
+	return wrapper.CreateConcurrentSwissMap[uint16, *couchbase.ObserverMetric](0)
+}
+
+func (o *stubObserver) GetQueueDepth() int {
+	return o.queueDepth
+}
+
+func (o *stubObserver) GetBackpressureCount() int64 {
+	return 0
+}
+
+func (o *stubObserver) GetSeqNoGapCount() int64 {
+	return 0
+}
+
+type stubStream struct {
+	stream.Stream
+	observer    couchbase.Observer
+	rateLimiter *helpers.RateLimiter
+	offsets     *wrapper.ConcurrentSwissMap[uint16, *models.Offset]
+}
+
+func (s *stubStream) GetRateLimiter() *helpers.RateLimiter {
+	return s.rateLimiter
+}
+
+func (s *stubStream) GetObserver() couchbase.Observer {
+	return s.observer
+}
+
+func (s *stubStream) GetOffsets() (*wrapper.ConcurrentSwissMap[uint16, *models.Offset], *wrapper.ConcurrentSwissMap[uint16, bool], bool) {
+	if s.offsets != nil {
+		return s.offsets, wrapper.CreateConcurrentSwissMap[uint16, bool](0), false
+	}
+
+	return wrapper.CreateConcurrentSwissMap[uint16, *models.Offset](0), wrapper.CreateConcurrentSwissMap[uint16, bool](0), false
+}
+
+func (s *stubStream) GetMetric() *stream.Metric {
+	return &stream.Metric{SnapshotSize: helpers.NewHistogram(nil)}
+}
+
+func (s *stubStream) GetCheckpointMetric() *stream.CheckpointMetric {
+	return &stream.CheckpointMetric{}
+}
+
+type stubClient struct {
+	couchbase.Client
+}
+
+func (c *stubClient) GetVBucketSeqNos() (map[uint16]uint64, error) {
+	return map[uint16]uint64{}, nil
+}
+
+func TestMetricCollector_DcpQueueDepth(t *testing.T) {
+	collector := newMetricCollector(
+		&stubClient{},
+		&stubStream{observer: &stubObserver{queueDepth: 42}},
+		&fakeVBucketDiscovery{},
+	)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, family := range families {
+		if family.GetName() == "cbgo_dcp_queue_depth" {
+			if value := family.GetMetric()[0].GetGauge().GetValue(); value != 42 {
+				t.Errorf("queue depth = %v, want 42", value)
+			}
+			return
+		}
+	}
+
+	t.Errorf("cbgo_dcp_queue_depth is not collected")
+}
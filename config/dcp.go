@@ -2,7 +2,9 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"regexp"
 	"strconv"
 	"time"
 
@@ -22,13 +24,46 @@ const (
 	CouchbaseMetadataConnectionBufferSizeConfig = "connectionBufferSize"
 	CouchbaseMetadataConnectionTimeoutConfig    = "connectionTimeout"
 	CheckpointTypeAuto                          = "auto"
+	MemberNumberCollisionPolicyStepBack         = "stepBack"
+	MemberNumberCollisionPolicyIgnore           = "ignore"
+	MaxTTLPolicyAdjust                          = "adjust"
+	MaxTTLPolicyFail                            = "fail"
+	FanOutAckPolicyAll                          = "all"
+	FanOutAckPolicyAny                          = "any"
+	APIPortInUsePolicyIgnore                    = "ignore"
+	APIPortInUsePolicyFail                      = "fail"
+	APIPortInUsePolicyRetryPort                 = "retryPort"
+	IndexCompressionGzip                        = "gzip"
+	IndexCompressionSnappy                      = "snappy"
+	OutOfRangePolicyPrune                       = "prune"
+	OutOfRangePolicyIgnore                      = "ignore"
+	PrunedSeqNoPolicyRollbackToAvailable        = "rollbackToAvailable"
+	PrunedSeqNoPolicyFail                       = "fail"
+	PrunedSeqNoPolicyRestartFromNow             = "restartFromNow"
 )
 
+type DCPMonitorInterval struct {
+	Min      time.Duration `yaml:"min"`
+	Max      time.Duration `yaml:"max"`
+	Adaptive bool          `yaml:"adaptive"`
+}
+
 type DCPGroupMembership struct {
-	Type           string        `yaml:"type"`
-	MemberNumber   int           `yaml:"memberNumber"`
-	TotalMembers   int           `yaml:"totalMembers"`
-	RebalanceDelay time.Duration `yaml:"rebalanceDelay"`
+	Tags              map[string]string  `yaml:"tags"`
+	MonitorInterval   DCPMonitorInterval `yaml:"monitorInterval"`
+	Type              string             `yaml:"type"`
+	CollisionPolicy   string             `yaml:"collisionPolicy"`
+	MaxTTLPolicy      string             `yaml:"maxTTLPolicy"`
+	IndexCompression  string             `yaml:"indexCompression"`
+	MemberNumber      int                `yaml:"memberNumber"`
+	TotalMembers      int                `yaml:"totalMembers"`
+	RebalanceDelay    time.Duration      `yaml:"rebalanceDelay"`
+	InfoTimeout       time.Duration      `yaml:"infoTimeout"`
+	StartBarrier      time.Duration      `yaml:"startBarrier"`
+	MinMembersTimeout time.Duration      `yaml:"minMembersTimeout"`
+	IndexReadAttempts int                `yaml:"indexReadAttempts"`
+	MinMembers        int                `yaml:"minMembers"`
+	ReadYourWrites    bool               `yaml:"readYourWrites"`
 }
 
 type DCPGroup struct {
@@ -36,8 +71,27 @@ type DCPGroup struct {
 	Membership DCPGroupMembership `yaml:"membership"`
 }
 
+type DCPSoftDelete struct {
+	Field string `yaml:"field"`
+	Value string `yaml:"value"`
+}
+
 type DCPListener struct {
-	BufferSize uint `yaml:"bufferSize"`
+	SoftDelete        DCPSoftDelete `yaml:"softDelete"`
+	FanOutAckPolicy   string        `yaml:"fanOutAckPolicy"`
+	BufferSize        uint          `yaml:"bufferSize"`
+	SeqNoGapThreshold uint64        `yaml:"seqNoGapThreshold"`
+	IdleInterval      time.Duration `yaml:"idleInterval"`
+}
+
+type DCPProcessing struct {
+	CollectionWorkers     map[string]int `yaml:"collectionWorkers"`
+	Workers               int            `yaml:"workers"`
+	PerVBucketConcurrency int            `yaml:"perVBucketConcurrency"`
+	MaxPendingAcks        int            `yaml:"maxPendingAcks"`
+	RebalanceConcurrency  int            `yaml:"rebalanceConcurrency"`
+	RateLimit             int            `yaml:"rateLimit"`
+	DrainOnRollback       bool           `yaml:"drainOnRollback"`
 }
 
 type ExternalDcp struct {
@@ -46,34 +100,52 @@ type ExternalDcp struct {
 	ConnectionBufferSize uint          `yaml:"connectionBufferSize"`
 	ConnectionTimeout    time.Duration `yaml:"connectionTimeout"`
 	Listener             DCPListener   `yaml:"listener"`
+	Processing           DCPProcessing `yaml:"processing"`
 }
 
 type API struct {
-	Disabled bool `yaml:"disabled"`
-	Port     int  `yaml:"port"`
+	PortInUsePolicy      string `yaml:"portInUsePolicy"`
+	Port                 int    `yaml:"port"`
+	PortRetries          int    `yaml:"portRetries"`
+	Disabled             bool   `yaml:"disabled"`
+	AllowRateLimitUpdate bool   `yaml:"allowRateLimitUpdate"`
 }
 
 type Metric struct {
-	Path             string  `yaml:"path"`
-	AverageWindowSec float64 `yaml:"averageWindowSec"`
+	Path             string        `yaml:"path"`
+	AverageWindowSec float64       `yaml:"averageWindowSec"`
+	CacheTTL         time.Duration `yaml:"cacheTTL"`
 }
 
 type LeaderElection struct {
-	Config  map[string]string `yaml:"config"`
-	Type    string            `yaml:"type"`
-	RPC     RPC               `yaml:"rpc"`
-	Enabled bool              `yaml:"enabled"`
+	Config        map[string]string `yaml:"config"`
+	Type          string            `yaml:"type"`
+	RPC           RPC               `yaml:"rpc"`
+	StepDownAfter time.Duration     `yaml:"stepDownAfter"`
+	Enabled       bool              `yaml:"enabled"`
 }
 
 type RPC struct {
-	Port int `yaml:"port"`
+	Port                 int `yaml:"port"`
+	MaxRebalanceFailures int `yaml:"maxRebalanceFailures"`
 }
 
 type Checkpoint struct {
-	Type      string        `yaml:"type"`
-	AutoReset string        `yaml:"autoReset"`
-	Interval  time.Duration `yaml:"interval"`
-	Timeout   time.Duration `yaml:"timeout"`
+	Type              string        `yaml:"type"`
+	AutoReset         string        `yaml:"autoReset"`
+	PrunedSeqNoPolicy string        `yaml:"prunedSeqNoPolicy"`
+	OutOfRangePolicy  string        `yaml:"outOfRangePolicy"`
+	Interval          time.Duration `yaml:"interval"`
+	Timeout           time.Duration `yaml:"timeout"`
+	LoadConcurrency   int           `yaml:"loadConcurrency"`
+}
+
+type Timeouts struct {
+	Register       time.Duration `yaml:"register"`
+	Heartbeat      time.Duration `yaml:"heartbeat"`
+	MonitorRead    time.Duration `yaml:"monitorRead"`
+	CheckpointSave time.Duration `yaml:"checkpointSave"`
+	ShutdownDrain  time.Duration `yaml:"shutdownDrain"`
 }
 
 type HealthCheck struct {
@@ -88,14 +160,21 @@ type RollbackMitigation struct {
 	ConfigWatchInterval time.Duration `yaml:"configWatchInterval"`
 }
 
+type MetadataMigrateFrom struct {
+	Scope      string `yaml:"scope"`
+	Collection string `yaml:"collection"`
+}
+
 type Metadata struct {
-	Config   map[string]string `yaml:"config"`
-	Type     string            `yaml:"type"`
-	ReadOnly bool              `json:"readOnly"`
+	Config      map[string]string   `yaml:"config"`
+	Type        string              `yaml:"type"`
+	MigrateFrom MetadataMigrateFrom `yaml:"migrateFrom"`
+	ReadOnly    bool                `json:"readOnly"`
 }
 
 type Logging struct {
-	Level string `yaml:"level"`
+	Level       string        `yaml:"level"`
+	DedupWindow time.Duration `yaml:"dedupWindow"`
 }
 
 type Dcp struct {
@@ -109,6 +188,7 @@ type Dcp struct {
 	CollectionNames      []string           `yaml:"collectionNames"`
 	Metric               Metric             `yaml:"metric"`
 	Checkpoint           Checkpoint         `yaml:"checkpoint"`
+	Timeouts             Timeouts           `yaml:"timeouts"`
 	LeaderElection       LeaderElection     `yaml:"leaderElector"`
 	Dcp                  ExternalDcp        `yaml:"dcp"`
 	HealthCheck          HealthCheck        `yaml:"healthCheck"`
@@ -161,6 +241,21 @@ func (c *Dcp) GetCouchbaseMetadata() (string, string, string, uint, time.Duratio
 		c.getMetadataConnectionTimeout()
 }
 
+// GetMetadataMigrateFrom returns the old scope and collection of couchbase metadata, unset ones are the current
+func (c *Dcp) GetMetadataMigrateFrom() (string, string, bool) {
+	scope, collection := c.Metadata.MigrateFrom.Scope, c.Metadata.MigrateFrom.Collection
+
+	if scope == "" {
+		scope = c.getMetadataScope()
+	}
+
+	if collection == "" {
+		collection = c.getMetadataCollection()
+	}
+
+	return scope, collection, scope != c.getMetadataScope() || collection != c.getMetadataCollection()
+}
+
 func (c *Dcp) getMetadataBucket() string {
 	if bucket, ok := c.Metadata.Config[CouchbaseMetadataBucketConfig]; ok {
 		return bucket
@@ -213,9 +308,39 @@ func (c *Dcp) getMetadataConnectionTimeout() time.Duration {
 	return 5 * time.Second
 }
 
+var (
+	ErrInvalidGroupName   = errors.New("invalid dcp group name")
+	ErrInvalidConfigValue = errors.New("invalid config value")
+
+	_groupNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+)
+
+// Validate checks the fields that are used to build document keys
+func (c *Dcp) Validate() error {
+	if !_groupNamePattern.MatchString(c.Dcp.Group.Name) {
+		return fmt.Errorf("%w: %q, it must be non-empty and contain only letters, digits, '_', '.' or '-'",
+			ErrInvalidGroupName, c.Dcp.Group.Name)
+	}
+
+	return nil
+}
+
+// mustBeOneOf panics when a value is not one of the allowed values of the field
+func mustBeOneOf(field string, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+
+	panic(fmt.Errorf("%w: %s is %q, it must be one of %q", ErrInvalidConfigValue, field, value, allowed))
+}
+
 func (c *Dcp) ApplyDefaults() {
 	c.applyDefaultRollbackMitigation()
 	c.applyDefaultCheckpoint()
+	c.applyDefaultTimeouts()
+	c.applyDefaultProcessing()
 	c.applyDefaultHealthCheck()
 	c.applyDefaultGroupMembership()
 	c.applyDefaultConnectionTimeout()
@@ -249,6 +374,10 @@ func (c *Dcp) applyDefaultCheckpoint() {
 		c.Checkpoint.Timeout = 60 * time.Second
 	}
 
+	if c.Checkpoint.LoadConcurrency == 0 {
+		c.Checkpoint.LoadConcurrency = 32
+	}
+
 	if c.Checkpoint.Type == "" {
 		c.Checkpoint.Type = "auto"
 	}
@@ -256,6 +385,42 @@ func (c *Dcp) applyDefaultCheckpoint() {
 	if c.Checkpoint.AutoReset == "" {
 		c.Checkpoint.AutoReset = "earliest"
 	}
+
+	if c.Checkpoint.OutOfRangePolicy == "" {
+		c.Checkpoint.OutOfRangePolicy = OutOfRangePolicyPrune
+	}
+
+	mustBeOneOf("checkpoint.outOfRangePolicy", c.Checkpoint.OutOfRangePolicy, OutOfRangePolicyPrune, OutOfRangePolicyIgnore)
+	mustBeOneOf("checkpoint.prunedSeqNoPolicy", c.Checkpoint.PrunedSeqNoPolicy,
+		"", PrunedSeqNoPolicyRollbackToAvailable, PrunedSeqNoPolicyFail, PrunedSeqNoPolicyRestartFromNow)
+}
+
+func (c *Dcp) applyDefaultTimeouts() {
+	if c.Timeouts.Register == 0 {
+		c.Timeouts.Register = 10 * time.Second
+	}
+
+	if c.Timeouts.Heartbeat == 0 {
+		c.Timeouts.Heartbeat = 10 * time.Second
+	}
+
+	if c.Timeouts.MonitorRead == 0 {
+		c.Timeouts.MonitorRead = 10 * time.Second
+	}
+
+	if c.Timeouts.CheckpointSave == 0 {
+		c.Timeouts.CheckpointSave = c.Checkpoint.Timeout
+	}
+
+	if c.Timeouts.ShutdownDrain == 0 {
+		c.Timeouts.ShutdownDrain = 10 * time.Second
+	}
+}
+
+func (c *Dcp) applyDefaultProcessing() {
+	if c.Dcp.Processing.PerVBucketConcurrency == 0 {
+		c.Dcp.Processing.PerVBucketConcurrency = 1
+	}
 }
 
 func (c *Dcp) applyDefaultHealthCheck() {
@@ -273,6 +438,42 @@ func (c *Dcp) applyDefaultGroupMembership() {
 		c.Dcp.Group.Membership.RebalanceDelay = 20 * time.Second
 	}
 
+	if c.Dcp.Group.Membership.InfoTimeout == 0 {
+		c.Dcp.Group.Membership.InfoTimeout = 3 * time.Minute
+	}
+
+	if c.Dcp.Group.Membership.IndexReadAttempts == 0 {
+		c.Dcp.Group.Membership.IndexReadAttempts = 3
+	}
+
+	if c.Dcp.Group.Membership.CollisionPolicy == "" {
+		c.Dcp.Group.Membership.CollisionPolicy = MemberNumberCollisionPolicyStepBack
+	}
+
+	mustBeOneOf("dcp.group.membership.collisionPolicy", c.Dcp.Group.Membership.CollisionPolicy,
+		MemberNumberCollisionPolicyStepBack, MemberNumberCollisionPolicyIgnore)
+
+	if c.Dcp.Group.Membership.MaxTTLPolicy == "" {
+		c.Dcp.Group.Membership.MaxTTLPolicy = MaxTTLPolicyAdjust
+	}
+
+	mustBeOneOf("dcp.group.membership.maxTTLPolicy", c.Dcp.Group.Membership.MaxTTLPolicy, MaxTTLPolicyAdjust, MaxTTLPolicyFail)
+
+	if c.Dcp.Group.Membership.MonitorInterval.Min == 0 {
+		c.Dcp.Group.Membership.MonitorInterval.Min = 500 * time.Millisecond
+	}
+
+	if c.Dcp.Group.Membership.MonitorInterval.Max == 0 {
+		c.Dcp.Group.Membership.MonitorInterval.Max = 5 * time.Second
+	}
+
+	mustBeOneOf("dcp.group.membership.indexCompression", c.Dcp.Group.Membership.IndexCompression,
+		"", IndexCompressionGzip, IndexCompressionSnappy)
+
+	if c.Dcp.Group.Membership.MinMembersTimeout == 0 {
+		c.Dcp.Group.Membership.MinMembersTimeout = time.Minute
+	}
+
 	if c.Dcp.Group.Membership.TotalMembers == 0 {
 		c.Dcp.Group.Membership.TotalMembers = 1
 	}
@@ -344,6 +545,17 @@ func (c *Dcp) applyDefaultAPI() {
 	if c.API.Port == 0 {
 		c.API.Port = 8080
 	}
+
+	if c.API.PortInUsePolicy == "" {
+		c.API.PortInUsePolicy = APIPortInUsePolicyIgnore
+	}
+
+	mustBeOneOf("api.portInUsePolicy", c.API.PortInUsePolicy,
+		APIPortInUsePolicyIgnore, APIPortInUsePolicyFail, APIPortInUsePolicyRetryPort)
+
+	if c.API.PortRetries == 0 {
+		c.API.PortRetries = 10
+	}
 }
 
 func (c *Dcp) applyDefaultLeaderElection() {
@@ -354,6 +566,10 @@ func (c *Dcp) applyDefaultLeaderElection() {
 	if c.LeaderElection.RPC.Port == 0 {
 		c.LeaderElection.RPC.Port = 8081
 	}
+
+	if c.LeaderElection.RPC.MaxRebalanceFailures == 0 {
+		c.LeaderElection.RPC.MaxRebalanceFailures = 3
+	}
 }
 
 func (c *Dcp) applyDefaultDcp() {
@@ -368,6 +584,12 @@ func (c *Dcp) applyDefaultDcp() {
 	if c.Dcp.Listener.BufferSize == 0 {
 		c.Dcp.Listener.BufferSize = 1000
 	}
+
+	if c.Dcp.Listener.FanOutAckPolicy == "" {
+		c.Dcp.Listener.FanOutAckPolicy = FanOutAckPolicyAll
+	}
+
+	mustBeOneOf("dcp.listener.fanOutAckPolicy", c.Dcp.Listener.FanOutAckPolicy, FanOutAckPolicyAll, FanOutAckPolicyAny)
 }
 
 func (c *Dcp) applyDefaultMetadata() {
@@ -377,14 +599,17 @@ func (c *Dcp) applyDefaultMetadata() {
 }
 
 func (c *Dcp) applyLogging() {
-	if logger.Log != nil {
-		return
-	}
+	if logger.Log == nil {
+		loggingLevel := c.Logging.Level
+		if loggingLevel == "" {
+			c.Logging.Level = logger.INFO
+		}
 
-	loggingLevel := c.Logging.Level
-	if loggingLevel == "" {
-		c.Logging.Level = logger.INFO
+		logger.InitDefaultLogger(c.Logging.Level)
 	}
 
-	logger.InitDefaultLogger(c.Logging.Level)
+	// the logger of NewDcpWithLogger is deduplicated too
+	if _, deduplicated := logger.Log.(*logger.DedupLogger); c.Logging.DedupWindow > 0 && !deduplicated {
+		logger.Log = logger.NewDedupLogger(logger.Log, c.Logging.DedupWindow)
+	}
 }
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"errors"
 	"testing"
 	"time"
+
+	"github.com/Trendyol/go-dcp/logger"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -74,6 +77,49 @@ func TestGetCouchbaseMetadata(t *testing.T) {
 	}
 }
 
+func TestGetMetadataMigrateFrom(t *testing.T) {
+	dcp := &Dcp{
+		Metadata: Metadata{
+			Config: map[string]string{
+				CouchbaseMetadataScopeConfig:      "myscope",
+				CouchbaseMetadataCollectionConfig: "mycollection",
+			},
+		},
+	}
+
+	if _, _, ok := dcp.GetMetadataMigrateFrom(); ok {
+		t.Errorf("Migration must be disabled when migrateFrom is not set")
+	}
+
+	dcp.Metadata.MigrateFrom = MetadataMigrateFrom{Collection: "oldcollection"}
+
+	scope, collection, ok := dcp.GetMetadataMigrateFrom()
+
+	if !ok || scope != "myscope" || collection != "oldcollection" {
+		t.Errorf("MigrateFrom = %v.%v, %v is not set to expected value", scope, collection, ok)
+	}
+}
+
+func TestDcp_ValidateGroupName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"":              false,
+		"group:name":    false,
+		"group name":    false,
+		"my-group_1.v2": true,
+	} {
+		dcp := &Dcp{Dcp: ExternalDcp{Group: DCPGroup{Name: name}}}
+
+		err := dcp.Validate()
+		if valid && err != nil {
+			t.Errorf("group name %q must be accepted, got %v", name, err)
+		}
+
+		if !valid && !errors.Is(err, ErrInvalidGroupName) {
+			t.Errorf("group name %q must be rejected, got %v", name, err)
+		}
+	}
+}
+
 func TestDcp_GetFileMetadata(t *testing.T) {
 	dcp := &Dcp{
 		Metadata: Metadata{
@@ -105,6 +151,15 @@ func TestApplyDefaultRollbackMitigation(t *testing.T) {
 	}
 }
 
+func TestDcpApplyDefaultProcessing(t *testing.T) {
+	c := &Dcp{}
+	c.applyDefaultProcessing()
+
+	if c.Dcp.Processing.PerVBucketConcurrency != 1 {
+		t.Errorf("Dcp.Processing.PerVBucketConcurrency is not set to expected value")
+	}
+}
+
 func TestDcpApplyDefaultCheckpoint(t *testing.T) {
 	c := &Dcp{}
 	c.applyDefaultCheckpoint()
@@ -117,6 +172,10 @@ func TestDcpApplyDefaultCheckpoint(t *testing.T) {
 		t.Errorf("Checkpoint.Timeout is not set to expected value")
 	}
 
+	if c.Checkpoint.LoadConcurrency != 32 {
+		t.Errorf("Checkpoint.LoadConcurrency is not set to expected value")
+	}
+
 	if c.Checkpoint.Type != CheckpointTypeAuto {
 		t.Errorf("Checkpoint.Type is not set to expected value")
 	}
@@ -126,6 +185,31 @@ func TestDcpApplyDefaultCheckpoint(t *testing.T) {
 	}
 }
 
+func TestDcpApplyDefaultTimeouts(t *testing.T) {
+	c := &Dcp{Checkpoint: Checkpoint{Timeout: 30 * time.Second}}
+	c.applyDefaultTimeouts()
+
+	if c.Timeouts.Register != 10*time.Second {
+		t.Errorf("Timeouts.Register is not set to expected value")
+	}
+
+	if c.Timeouts.Heartbeat != 10*time.Second {
+		t.Errorf("Timeouts.Heartbeat is not set to expected value")
+	}
+
+	if c.Timeouts.MonitorRead != 10*time.Second {
+		t.Errorf("Timeouts.MonitorRead is not set to expected value")
+	}
+
+	if c.Timeouts.CheckpointSave != 30*time.Second {
+		t.Errorf("Timeouts.CheckpointSave is not set to expected value")
+	}
+
+	if c.Timeouts.ShutdownDrain != 10*time.Second {
+		t.Errorf("Timeouts.ShutdownDrain is not set to expected value")
+	}
+}
+
 func TestDcpApplyDefaultHealthCheck(t *testing.T) {
 	c := &Dcp{}
 	c.applyDefaultHealthCheck()
@@ -151,6 +235,34 @@ func TestDcpApplyDefaultGroupMembership(t *testing.T) {
 		t.Errorf("Dcp.Group.Membership.RebalanceDelay is not set to expected value")
 	}
 
+	if c.Dcp.Group.Membership.InfoTimeout != 3*time.Minute {
+		t.Errorf("Dcp.Group.Membership.InfoTimeout is not set to expected value")
+	}
+
+	if c.Dcp.Group.Membership.IndexReadAttempts != 3 {
+		t.Errorf("Dcp.Group.Membership.IndexReadAttempts is not set to expected value")
+	}
+
+	if c.Dcp.Group.Membership.CollisionPolicy != MemberNumberCollisionPolicyStepBack {
+		t.Errorf("Dcp.Group.Membership.CollisionPolicy is not set to expected value")
+	}
+
+	if c.Dcp.Group.Membership.MaxTTLPolicy != MaxTTLPolicyAdjust {
+		t.Errorf("Dcp.Group.Membership.MaxTTLPolicy is not set to expected value")
+	}
+
+	if c.Dcp.Group.Membership.MonitorInterval.Min != 500*time.Millisecond {
+		t.Errorf("Dcp.Group.Membership.MonitorInterval.Min is not set to expected value")
+	}
+
+	if c.Dcp.Group.Membership.MonitorInterval.Max != 5*time.Second {
+		t.Errorf("Dcp.Group.Membership.MonitorInterval.Max is not set to expected value")
+	}
+
+	if c.Dcp.Group.Membership.MinMembersTimeout != time.Minute {
+		t.Errorf("Dcp.Group.Membership.MinMembersTimeout is not set to expected value")
+	}
+
 	if c.Dcp.Group.Membership.TotalMembers != 1 {
 		t.Errorf("Dcp.Group.Membership.TotalMembers is not set to expected value")
 	}
@@ -164,6 +276,49 @@ func TestDcpApplyDefaultGroupMembership(t *testing.T) {
 	}
 }
 
+func TestDcpApplyDefaultsRejectsUnknownValues(t *testing.T) {
+	assertRejected := func(name string, c *Dcp) {
+		t.Helper()
+
+		defer func() {
+			err, _ := recover().(error)
+			if !errors.Is(err, ErrInvalidConfigValue) {
+				t.Errorf("%v: recovered %v, want %v", name, err, ErrInvalidConfigValue)
+			}
+		}()
+
+		c.ApplyDefaults()
+	}
+
+	c := &Dcp{}
+	c.Dcp.Group.Membership.IndexCompression = "lz4"
+	assertRejected("indexCompression", c)
+
+	c = &Dcp{}
+	c.Checkpoint.OutOfRangePolicy = "delete"
+	assertRejected("outOfRangePolicy", c)
+
+	c = &Dcp{}
+	c.Checkpoint.PrunedSeqNoPolicy = "rollback"
+	assertRejected("prunedSeqNoPolicy", c)
+
+	c = &Dcp{}
+	c.Dcp.Group.Membership.CollisionPolicy = "stepDown"
+	assertRejected("collisionPolicy", c)
+
+	c = &Dcp{}
+	c.Dcp.Group.Membership.MaxTTLPolicy = "truncate"
+	assertRejected("maxTTLPolicy", c)
+
+	c = &Dcp{}
+	c.API.PortInUsePolicy = "nextPort"
+	assertRejected("portInUsePolicy", c)
+
+	c = &Dcp{}
+	c.Dcp.Listener.FanOutAckPolicy = "majority"
+	assertRejected("fanOutAckPolicy", c)
+}
+
 func TestDcpApplyDefaultConnectionTimeout(t *testing.T) {
 	c := &Dcp{}
 	c.applyDefaultConnectionTimeout()
@@ -228,6 +383,14 @@ func TestDcpApplyDefaultAPI(t *testing.T) {
 	if c.API.Port != 8080 {
 		t.Errorf("API.Port is not set to expected value")
 	}
+
+	if c.API.PortInUsePolicy != APIPortInUsePolicyIgnore {
+		t.Errorf("API.PortInUsePolicy is not set to expected value")
+	}
+
+	if c.API.PortRetries != 10 {
+		t.Errorf("API.PortRetries is not set to expected value")
+	}
 }
 
 func TestDcpApplyDefaultLeaderElection(t *testing.T) {
@@ -245,6 +408,10 @@ func TestDcpApplyDefaultLeaderElection(t *testing.T) {
 	if c.LeaderElection.RPC.Port != 8081 {
 		t.Errorf("LeaderElection.RPC.Port is not set to expected value")
 	}
+
+	if c.LeaderElection.RPC.MaxRebalanceFailures != 3 {
+		t.Errorf("LeaderElection.RPC.MaxRebalanceFailures is not set to expected value")
+	}
 }
 
 func TestDcpApplyDefaultDcp(t *testing.T) {
@@ -262,6 +429,10 @@ func TestDcpApplyDefaultDcp(t *testing.T) {
 	if c.Dcp.Listener.BufferSize != 1000 {
 		t.Errorf("Dcp.Listener.BufferSize is not set to expected value")
 	}
+
+	if c.Dcp.Listener.FanOutAckPolicy != FanOutAckPolicyAll {
+		t.Errorf("Dcp.Listener.FanOutAckPolicy is not set to expected value")
+	}
 }
 
 func TestApplyDefaultMetadata(t *testing.T) {
@@ -279,3 +450,20 @@ func TestApplyDefaultMetadata(t *testing.T) {
 		t.Errorf("Metadata.Type is not set to expected value")
 	}
 }
+
+func TestDcpApplyDefaultsDeduplicatesCustomLogger(t *testing.T) {
+	previous := logger.Log
+	defer func() { logger.Log = previous }()
+
+	logger.InitDefaultLogger(logger.ERROR)
+	custom := logger.Log
+
+	c := &Dcp{}
+	c.Logging.DedupWindow = time.Second
+	c.ApplyDefaults()
+	c.ApplyDefaults()
+
+	if _, ok := logger.Log.(*logger.DedupLogger); !ok || logger.Log == custom {
+		t.Fatalf("logger = %T, want the custom logger deduplicated", logger.Log)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/couchbase/gocbcore/v10/connstr"
@@ -14,6 +16,8 @@ import (
 
 	"github.com/google/uuid"
 
+	"github.com/json-iterator/go"
+
 	"github.com/Trendyol/go-dcp/logger"
 	"github.com/Trendyol/go-dcp/models"
 
@@ -30,19 +34,24 @@ type Client interface {
 	DcpConnect() error
 	DcpClose()
 	GetVBucketSeqNos() (map[uint16]uint64, error)
+	GetVBucketPurgeSeqNos() (map[uint16]uint64, error)
 	GetNumVBuckets() int
 	GetFailoverLogs(vbID uint16) ([]gocbcore.FailoverEntry, error)
 	OpenStream(vbID uint16, collectionIDs map[uint32]string, offset *models.Offset, observer Observer) error
 	CloseStream(vbID uint16) error
 	GetCollectionIDs(scopeName string, collectionNames []string) map[uint32]string
+	GetMetaCollectionMaxTTL(scopeName string, collectionName string) (uint32, error)
 	GetConfigSnapshot() (*gocbcore.ConfigSnapshot, error)
+	GetBucketUUID() (string, error)
+	GetDcpConnectionNames() []string
 }
 
 type client struct {
-	agent     *gocbcore.Agent
-	metaAgent *gocbcore.Agent
-	dcpAgent  *gocbcore.DCPAgent
-	config    *config.Dcp
+	agent             *gocbcore.Agent
+	metaAgent         *gocbcore.Agent
+	dcpAgent          *gocbcore.DCPAgent
+	config            *config.Dcp
+	dcpConnectionName string
 }
 
 func (s *client) Ping() error {
@@ -181,12 +190,46 @@ func CreateAgent(httpAddresses []string, bucketName string,
 	}
 
 	if err = <-ch; err != nil {
-		return nil, err
+		return nil, wrapBucketError(err, bucketName, httpAddresses)
 	}
 
 	return agent, nil
 }
 
+// ErrBucketNotReady is returned when the bucket does not become ready before the connection timeout
+var ErrBucketNotReady = errors.New("bucket not ready")
+
+func isBucketNotReadyError(err error) bool {
+	var timeoutErr *gocbcore.TimeoutError
+	if errors.As(err, &timeoutErr) {
+		for _, reason := range timeoutErr.RetryReasons {
+			if reason == gocbcore.BucketNotReadyReason {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func wrapBucketError(err error, bucketName string, hosts []string) error {
+	if errors.Is(err, gocbcore.ErrBucketNotFound) {
+		return fmt.Errorf(
+			"%w: bucket %q does not exist on hosts %v or the user has no access to it, cause: %v",
+			gocbcore.ErrBucketNotFound, bucketName, hosts, err,
+		)
+	}
+
+	if isBucketNotReadyError(err) {
+		return fmt.Errorf(
+			"%w: bucket %q on hosts %v is not ready yet, it may be warming up or rebalancing, cause: %v",
+			ErrBucketNotReady, bucketName, hosts, err,
+		)
+	}
+
+	return err
+}
+
 func (s *client) connect(bucketName string, connectionBufferSize uint, connectionTimeout time.Duration) (*gocbcore.Agent, error) {
 	return CreateAgent(s.config.Hosts, bucketName, s.config.Username, s.config.Password, s.config.SecureConnection, s.config.RootCAPath, connectionBufferSize, connectionTimeout) //nolint:lll
 }
@@ -297,9 +340,11 @@ func (s *client) DcpConnect() error {
 		}
 	}
 
+	connectionName := fmt.Sprintf("%s_%s", s.config.Dcp.Group.Name, uuid.New().String())
+
 	client, err := gocbcore.CreateDcpAgent(
 		agentConfig,
-		fmt.Sprintf("%s_%s", s.config.Dcp.Group.Name, uuid.New().String()),
+		connectionName,
 		memd.DcpOpenFlagProducer,
 	)
 	if err != nil {
@@ -327,11 +372,20 @@ func (s *client) DcpConnect() error {
 	}
 
 	s.dcpAgent = client
-	logger.Log.Info("connected to %s as dcp, bucket: %s", s.config.Hosts, s.config.BucketName)
+	s.dcpConnectionName = connectionName
+	logger.Log.Info("connected to %s as dcp, bucket: %s, connection name: %s", s.config.Hosts, s.config.BucketName, connectionName)
 
 	return nil
 }
 
+func (s *client) GetDcpConnectionNames() []string {
+	if s.dcpConnectionName == "" {
+		return []string{}
+	}
+
+	return []string{s.dcpConnectionName}
+}
+
 func (s *client) DcpClose() {
 	_ = s.dcpAgent.Close()
 	logger.Log.Info("dcp connection closed %s", s.config.Hosts)
@@ -378,6 +432,67 @@ func (s *client) GetVBucketSeqNos() (map[uint16]uint64, error) {
 	return seqNos, nil
 }
 
+func (s *client) GetVBucketPurgeSeqNos() (map[uint16]uint64, error) {
+	opm := NewAsyncOp(context.Background())
+	ch := make(chan error, 1)
+
+	var purgeSeqNos map[uint16]uint64
+
+	op, err := s.agent.Stats(
+		gocbcore.StatsOptions{
+			Key:      "vbucket-details",
+			Deadline: time.Now().Add(s.config.ConnectionTimeout),
+		},
+		func(result *gocbcore.StatsResult, err error) {
+			if err == nil {
+				purgeSeqNos, err = parsePurgeSeqNos(result)
+			}
+
+			opm.Resolve()
+
+			ch <- err
+		},
+	)
+
+	err = opm.Wait(op, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return purgeSeqNos, <-ch
+}
+
+// parsePurgeSeqNos reads vb_<id>:purge_seqno of active vBuckets from vbucket-details stats
+func parsePurgeSeqNos(result *gocbcore.StatsResult) (map[uint16]uint64, error) {
+	purgeSeqNos := make(map[uint16]uint64)
+
+	for server, serverStats := range result.Servers {
+		if serverStats.Error != nil {
+			return nil, fmt.Errorf("cannot get vbucket details from %s: %w", server, serverStats.Error)
+		}
+
+		for key, state := range serverStats.Stats {
+			if !strings.HasPrefix(key, "vb_") || strings.Contains(key, ":") || state != "active" {
+				continue
+			}
+
+			vbID, err := strconv.ParseUint(strings.TrimPrefix(key, "vb_"), 10, 16)
+			if err != nil {
+				return nil, err
+			}
+
+			purgeSeqNo, err := strconv.ParseUint(serverStats.Stats[key+":purge_seqno"], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+
+			purgeSeqNos[uint16(vbID)] = purgeSeqNo
+		}
+	}
+
+	return purgeSeqNos, nil
+}
+
 func (s *client) GetNumVBuckets() int {
 	snapshot, err := s.GetConfigSnapshot()
 	if err != nil {
@@ -398,6 +513,51 @@ func (s *client) GetConfigSnapshot() (*gocbcore.ConfigSnapshot, error) { //nolin
 	return s.dcpAgent.ConfigSnapshot()
 }
 
+func (s *client) GetBucketUUID() (string, error) {
+	snapshot, err := s.GetConfigSnapshot()
+	if err != nil {
+		return "", err
+	}
+
+	return snapshot.BucketUUID(), nil
+}
+
+// getSourceNode returns the kv address of the active vBucket, it is empty when the config can not resolve it
+func (s *client) getSourceNode(vbID uint16) string {
+	// the server index and the endpoints are resolved from the same agent so they index the same server list
+	snapshot, err := s.agent.ConfigSnapshot()
+	if err != nil {
+		return ""
+	}
+
+	serverIdx, err := snapshot.VbucketToServer(vbID, 0)
+	if err != nil {
+		return ""
+	}
+
+	memdEps := s.agent.MemdEps()
+
+	// the config is changed between the reads
+	if numServers, err := snapshot.NumServers(); err != nil || numServers != len(memdEps) {
+		return ""
+	}
+
+	return findSourceNode(memdEps, serverIdx)
+}
+
+func findSourceNode(memdEps []string, serverIdx int) string {
+	if serverIdx < 0 || serverIdx >= len(memdEps) {
+		return ""
+	}
+
+	endpoint := memdEps[serverIdx]
+	if i := strings.Index(endpoint, "://"); i >= 0 {
+		endpoint = endpoint[i+3:]
+	}
+
+	return endpoint
+}
+
 func (s *client) GetFailoverLogs(vbID uint16) ([]gocbcore.FailoverEntry, error) {
 	opm := NewAsyncOp(context.Background())
 	ch := make(chan error)
@@ -433,6 +593,8 @@ func (s *client) openStreamWithRollback(vbID uint16,
 		vbID, failedSeqNo, rollbackSeqNo,
 	)
 
+	observer.Rollback(vbID, rollbackSeqNo)
+
 	opm := NewAsyncOp(context.Background())
 
 	ch := make(chan error)
@@ -450,6 +612,7 @@ func (s *client) openStreamWithRollback(vbID uint16,
 		func(failoverLogs []gocbcore.FailoverEntry, err error) {
 			if err == nil {
 				observer.SetVbUUID(vbID, failoverLogs[0].VbUUID)
+				observer.SetSourceNode(vbID, s.getSourceNode(vbID))
 				observer.AddCatchup(vbID, failedSeqNo)
 			}
 
@@ -504,6 +667,7 @@ func (s *client) OpenStream(
 		func(failoverLogs []gocbcore.FailoverEntry, err error) {
 			if err == nil {
 				observer.SetVbUUID(vbID, failoverLogs[0].VbUUID)
+				observer.SetSourceNode(vbID, s.getSourceNode(vbID))
 			}
 
 			opm.Resolve()
@@ -598,6 +762,51 @@ func (s *client) GetCollectionIDs(scopeName string, collectionNames []string) ma
 	return collectionIDs
 }
 
+func (s *client) GetMetaCollectionMaxTTL(scopeName string, collectionName string) (uint32, error) {
+	opm := NewAsyncOp(context.Background())
+
+	ch := make(chan error)
+	var manifest gocbcore.Manifest
+	op, err := s.metaAgent.GetCollectionManifest(
+		gocbcore.GetCollectionManifestOptions{},
+		func(result *gocbcore.GetCollectionManifestResult, err error) {
+			if err == nil {
+				err = jsoniter.Unmarshal(result.Manifest, &manifest)
+			}
+
+			opm.Resolve()
+
+			ch <- err
+		},
+	)
+	err = opm.Wait(op, err)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = <-ch; err != nil {
+		return 0, err
+	}
+
+	return findCollectionMaxTTL(&manifest, scopeName, collectionName)
+}
+
+func findCollectionMaxTTL(manifest *gocbcore.Manifest, scopeName string, collectionName string) (uint32, error) {
+	for _, scope := range manifest.Scopes {
+		if scope.Name != scopeName {
+			continue
+		}
+
+		for _, collection := range scope.Collections {
+			if collection.Name == collectionName {
+				return collection.MaxTTL, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %s.%s", gocbcore.ErrCollectionNotFound, scopeName, collectionName)
+}
+
 func NewClient(config *config.Dcp) Client {
 	return &client{
 		agent:    nil,
@@ -0,0 +1,91 @@
+package couchbase
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/couchbase/gocbcore/v10"
+)
+
+func TestWrapBucketError_ReturnsFriendlyErrorForUnknownBucket(t *testing.T) {
+	wrapped := wrapBucketError(gocbcore.ErrBucketNotFound, "dcp-test", []string{"localhost:8091"})
+
+	if !errors.Is(wrapped, gocbcore.ErrBucketNotFound) {
+		t.Errorf("error %v is not bucket not found", wrapped)
+	}
+
+	if !strings.Contains(wrapped.Error(), `"dcp-test"`) || !strings.Contains(wrapped.Error(), "localhost:8091") {
+		t.Errorf("error %v does not name the bucket and the hosts", wrapped)
+	}
+}
+
+func TestWrapBucketError_ReturnsNotReadyForBucketNotReadyTimeout(t *testing.T) {
+	err := &gocbcore.TimeoutError{
+		InnerError:   gocbcore.ErrUnambiguousTimeout,
+		OperationID:  "WaitUntilReady",
+		RetryReasons: []gocbcore.RetryReason{gocbcore.BucketNotReadyReason},
+	}
+
+	wrapped := wrapBucketError(err, "dcp-test", []string{"localhost:8091"})
+
+	if !errors.Is(wrapped, ErrBucketNotReady) || errors.Is(wrapped, gocbcore.ErrBucketNotFound) {
+		t.Errorf("error %v is not only bucket not ready", wrapped)
+	}
+
+	if !strings.Contains(wrapped.Error(), `"dcp-test"`) || !strings.Contains(wrapped.Error(), "localhost:8091") {
+		t.Errorf("error %v does not name the bucket and the hosts", wrapped)
+	}
+}
+
+func TestWrapBucketError_KeepsOtherErrors(t *testing.T) {
+	err := &gocbcore.TimeoutError{
+		InnerError:   gocbcore.ErrUnambiguousTimeout,
+		RetryReasons: []gocbcore.RetryReason{gocbcore.ConnectionErrorRetryReason},
+	}
+
+	if wrapped := wrapBucketError(err, "dcp-test", nil); wrapped != err {
+		t.Errorf("error = %v, want %v", wrapped, err)
+	}
+}
+
+func TestFindCollectionMaxTTL_ReadsMaxTTLFromManifest(t *testing.T) {
+	var manifest gocbcore.Manifest
+	err := manifest.UnmarshalJSON([]byte(`{"uid":"1","scopes":[{"uid":"8","name":"dcp","collections":[{"uid":"9","name":"metadata","maxTTL":5}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	maxTTL, err := findCollectionMaxTTL(&manifest, "dcp", "metadata")
+	if err != nil || maxTTL != 5 {
+		t.Errorf("max ttl = %v, %v, want 5", maxTTL, err)
+	}
+
+	if _, err := findCollectionMaxTTL(&manifest, "dcp", "unknown"); !errors.Is(err, gocbcore.ErrCollectionNotFound) {
+		t.Errorf("err = %v, want %v", err, gocbcore.ErrCollectionNotFound)
+	}
+}
+
+func TestFindSourceNode_ResolvesServerIndex(t *testing.T) {
+	memdEps := []string{"couchbase://10.0.0.1:11210", "couchbases://10.0.0.2:11207"}
+
+	for serverIdx, want := range map[int]string{0: "10.0.0.1:11210", 1: "10.0.0.2:11207", 2: "", -1: ""} {
+		if node := findSourceNode(memdEps, serverIdx); node != want {
+			t.Errorf("server %v source node = %q, want %q", serverIdx, node, want)
+		}
+	}
+}
+
+func TestClient_DcpConnectionNamesAreEmptyBeforeConnect(t *testing.T) {
+	c := &client{}
+
+	if names := c.GetDcpConnectionNames(); len(names) != 0 {
+		t.Errorf("names = %v, want none before dcp connect", names)
+	}
+
+	c.dcpConnectionName = "group_0b1c9a7e"
+
+	if names := c.GetDcpConnectionNames(); len(names) != 1 || names[0] != "group_0b1c9a7e" {
+		t.Errorf("names = %v, want the dcp connection name", names)
+	}
+}
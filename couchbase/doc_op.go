@@ -89,6 +89,84 @@ func UpdateDocument(ctx context.Context,
 	return err
 }
 
+func InsertDocument(ctx context.Context,
+	agent *gocbcore.Agent,
+	scopeName string,
+	collectionName string,
+	id []byte,
+	value []byte,
+	flags uint32,
+	expiry uint32,
+) error {
+	opm := NewAsyncOp(ctx)
+
+	deadline, _ := ctx.Deadline()
+
+	ch := make(chan error)
+
+	op, err := agent.Add(gocbcore.AddOptions{
+		Key:            id,
+		Value:          value,
+		Flags:          flags,
+		Deadline:       deadline,
+		Expiry:         expiry,
+		ScopeName:      scopeName,
+		CollectionName: collectionName,
+	}, func(result *gocbcore.StoreResult, err error) {
+		opm.Resolve()
+
+		ch <- err
+	})
+
+	err = opm.Wait(op, err)
+
+	if err != nil {
+		return err
+	}
+
+	return <-ch
+}
+
+func ReplaceDocument(ctx context.Context,
+	agent *gocbcore.Agent,
+	scopeName string,
+	collectionName string,
+	id []byte,
+	value []byte,
+	flags uint32,
+	expiry uint32,
+	cas gocbcore.Cas,
+) error {
+	opm := NewAsyncOp(ctx)
+
+	deadline, _ := ctx.Deadline()
+
+	ch := make(chan error)
+
+	op, err := agent.Replace(gocbcore.ReplaceOptions{
+		Key:            id,
+		Value:          value,
+		Flags:          flags,
+		Cas:            cas,
+		Deadline:       deadline,
+		Expiry:         expiry,
+		ScopeName:      scopeName,
+		CollectionName: collectionName,
+	}, func(result *gocbcore.StoreResult, err error) {
+		opm.Resolve()
+
+		ch <- err
+	})
+
+	err = opm.Wait(op, err)
+
+	if err != nil {
+		return err
+	}
+
+	return <-ch
+}
+
 func DeleteDocument(ctx context.Context, agent *gocbcore.Agent, scopeName string, collectionName string, id []byte) error {
 	opm := NewAsyncOp(ctx)
 
@@ -240,6 +318,43 @@ func Get(ctx context.Context, agent *gocbcore.Agent, scopeName string, collectio
 	return document, err
 }
 
+func GetWithCas(ctx context.Context, agent *gocbcore.Agent, scopeName string, collectionName string, id []byte) ([]byte, gocbcore.Cas, error) { //nolint:lll
+	opm := NewAsyncOp(ctx)
+
+	deadline, _ := ctx.Deadline()
+
+	// buffered since the callback of a cancelled op is not received
+	errorCh := make(chan error, 1)
+	resultCh := make(chan *gocbcore.GetResult, 1)
+
+	op, err := agent.Get(gocbcore.GetOptions{
+		Key:            id,
+		Deadline:       deadline,
+		ScopeName:      scopeName,
+		CollectionName: collectionName,
+	}, func(result *gocbcore.GetResult, err error) {
+		opm.Resolve()
+
+		resultCh <- result
+		errorCh <- err
+	})
+
+	err = opm.Wait(op, err)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result := <-resultCh
+	err = <-errorCh
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return result.Value, result.Cas, nil
+}
+
 func CreatePath(ctx context.Context,
 	agent *gocbcore.Agent,
 	scopeName string,
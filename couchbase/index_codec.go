@@ -0,0 +1,93 @@
+package couchbase
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Trendyol/go-dcp/config"
+
+	"github.com/golang/snappy"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// _indexCompressionMarker starts the compressed index documents, json documents can not start with it
+const _indexCompressionMarker byte = 0x00
+
+const (
+	_indexCodecGzip   byte = 'g'
+	_indexCodecSnappy byte = 's'
+)
+
+var ErrUnknownIndexCompression = errors.New("unknown index compression")
+
+func isCompressedIndex(data []byte) bool {
+	return len(data) >= 2 && data[0] == _indexCompressionMarker
+}
+
+// encodeIndex compresses the index as the marker, the codec and the compressed json if compression is set
+func encodeIndex(all map[string]int64, compression string) ([]byte, error) {
+	payload, err := jsoniter.Marshal(all)
+	if err != nil {
+		return nil, err
+	}
+
+	switch compression {
+	case "":
+		return payload, nil
+	case config.IndexCompressionSnappy:
+		return append([]byte{_indexCompressionMarker, _indexCodecSnappy}, snappy.Encode(nil, payload)...), nil
+	case config.IndexCompressionGzip:
+		var buf bytes.Buffer
+		buf.Write([]byte{_indexCompressionMarker, _indexCodecGzip})
+
+		writer := gzip.NewWriter(&buf)
+		if _, err = writer.Write(payload); err != nil {
+			return nil, err
+		}
+
+		if err = writer.Close(); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownIndexCompression, compression)
+	}
+}
+
+// decodeIndex reads both the compressed and the plain json index documents
+func decodeIndex(data []byte) (map[string]int64, error) {
+	payload := data
+
+	if isCompressedIndex(data) {
+		var err error
+
+		switch data[1] {
+		case _indexCodecSnappy:
+			payload, err = snappy.Decode(nil, data[2:])
+		case _indexCodecGzip:
+			var reader *gzip.Reader
+			reader, err = gzip.NewReader(bytes.NewReader(data[2:]))
+			if err == nil {
+				payload, err = io.ReadAll(reader)
+			}
+		default:
+			err = fmt.Errorf("%w: codec %q", ErrUnknownIndexCompression, data[1])
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	all := map[string]int64{}
+
+	if err := jsoniter.Unmarshal(payload, &all); err != nil {
+		return nil, err
+	}
+
+	return all, nil
+}
@@ -0,0 +1,101 @@
+package couchbase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Trendyol/go-dcp/config"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+func TestIndexCodec_RoundTripsCompressedIndex(t *testing.T) {
+	all := map[string]int64{"instance-1": 1, "instance-2": 2}
+
+	for _, compression := range []string{"", config.IndexCompressionGzip, config.IndexCompressionSnappy} {
+		data, err := encodeIndex(all, compression)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if compressed := data[0] == _indexCompressionMarker; compressed != (compression != "") {
+			t.Errorf("%q: compression marker is %v", compression, compressed)
+		}
+
+		decoded, err := decodeIndex(data)
+		if err != nil {
+			t.Fatalf("%q: %v", compression, err)
+		}
+
+		if len(decoded) != 2 || decoded["instance-1"] != 1 || decoded["instance-2"] != 2 {
+			t.Errorf("%q: decoded = %v", compression, decoded)
+		}
+	}
+
+	if _, err := encodeIndex(all, "lz4"); err == nil {
+		t.Errorf("unknown compression must fail")
+	}
+}
+
+func TestCBMembership_CompressedIndexInteroperatesWithPlainIndex(t *testing.T) {
+	c := &config.Dcp{}
+	c.Dcp.Group.Membership.IndexCompression = config.IndexCompressionGzip
+	c.ApplyDefaults()
+
+	// written by an instance without compression
+	store := &fakeMembershipStore{
+		docs:    map[string][]byte{"all": []byte(`{"old":1}`)},
+		updates: map[string][]byte{},
+	}
+
+	h := &cbMembership{config: c, store: store, id: []byte("new"), instanceAll: []byte("all")}
+
+	if _, err := h.createIndex(context.Background(), 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if store.docs["all"][0] != _indexCompressionMarker {
+		t.Fatalf("index is not compressed: %q", store.docs["all"])
+	}
+
+	all, err := decodeIndex(store.docs["all"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(all) != 2 || all["old"] != 1 || all["new"] != 2 {
+		t.Errorf("index = %v, want the plain entry kept with the new one", all)
+	}
+}
+
+func TestCBMembership_ReaderWithoutCompressionRewritesCompressedIndex(t *testing.T) {
+	c := &config.Dcp{}
+	c.ApplyDefaults()
+
+	compressed, err := encodeIndex(map[string]int64{"new": 1}, config.IndexCompressionSnappy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// written by an instance with compression
+	store := &fakeMembershipStore{
+		docs:    map[string][]byte{"all": compressed},
+		reads:   map[string]int{},
+		updates: map[string][]byte{},
+	}
+
+	h := &cbMembership{config: c, store: store, id: []byte("plain"), instanceAll: []byte("all")}
+
+	if _, err := h.createIndex(context.Background(), 2); err != nil {
+		t.Fatal(err)
+	}
+
+	all := map[string]int64{}
+	if err := jsoniter.Unmarshal(store.docs["all"], &all); err != nil {
+		t.Fatalf("index is not rewritten as json: %v", err)
+	}
+
+	if len(all) != 2 || all["new"] != 1 || all["plain"] != 2 {
+		t.Errorf("index = %v, want the compressed entry kept with the plain one", all)
+	}
+}
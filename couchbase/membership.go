@@ -3,8 +3,10 @@ package couchbase
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Trendyol/go-dcp/config"
@@ -12,6 +14,7 @@ import (
 	"github.com/Trendyol/go-dcp/helpers"
 	"github.com/Trendyol/go-dcp/logger"
 	"github.com/Trendyol/go-dcp/membership"
+	"github.com/Trendyol/go-dcp/models"
 
 	"github.com/json-iterator/go"
 
@@ -23,87 +26,250 @@ import (
 
 type cbMembership struct {
 	client              Client
+	store               membershipStore
 	bus                 helpers.Bus
-	info                *membership.Model
+	info                atomic.Pointer[membership.Model]
 	infoChan            chan *membership.Model
+	closeCh             chan struct{}
 	heartbeatTicker     *time.Ticker
 	config              *config.Dcp
 	monitorTicker       *time.Ticker
 	scopeName           string
 	collectionName      string
+	monitorTickDuration *helpers.Histogram
+	goroutines          *helpers.Goroutines
 	lastActiveInstances []Instance
+	minMembersDeadline  time.Time
+	instancesLock       sync.RWMutex
+	registerLock        sync.Mutex
+	infoSenders         sync.WaitGroup
+	closeLock           sync.Mutex
 	instanceAll         []byte
 	id                  []byte
-	clusterJoinTime     int64
+	clusterJoinTime     atomic.Int64
+	assignedAt          int64
+	collisions          int64
+	heartbeatInterval   time.Duration
+	monitorInterval     time.Duration
+	expirySec           uint32
 }
 
 type Instance struct {
-	ID              *string `json:"id,omitempty"`
-	Type            string  `json:"type"`
-	HeartbeatTime   int64   `json:"heartbeatTime"`
-	ClusterJoinTime int64   `json:"clusterJoinTime"`
+	ID              *string           `json:"id,omitempty"`
+	Tags            map[string]string `json:"tags,omitempty"`
+	Type            string            `json:"type"`
+	HeartbeatTime   int64             `json:"heartbeatTime"`
+	ClusterJoinTime int64             `json:"clusterJoinTime"`
+	MemberNumber    int               `json:"memberNumber,omitempty"`
+}
+
+type ClusterMembership interface {
+	membership.Membership
+	GetInstances() []Instance
+	GetMemberNumberCollisions() int64
+	GetMonitorTickDuration() *helpers.Histogram
 }
 
 const (
-	_type                  = "instance"
-	_expirySec             = 10
-	_heartbeatIntervalSec  = 5
-	_heartbeatToleranceSec = 2
-	_monitorIntervalMs     = 500
-	_timeoutSec            = 10
+	_type                     = "instance"
+	_expirySec                = 10
+	_heartbeatIntervalSec     = 5
+	_heartbeatToleranceSec    = 2
+	_indexReadRetryIntervalMs = 100
 )
 
+var _monitorTickDurationBuckets = []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
 func (h *cbMembership) GetInfo() *membership.Model {
-	if h.info != nil {
-		return h.info
+	if info := h.info.Load(); info != nil {
+		return info
 	}
 
 	return <-h.infoChan
 }
 
+func (h *cbMembership) GetInfoContext(ctx context.Context) (*membership.Model, error) {
+	if info := h.info.Load(); info != nil {
+		return info, nil
+	}
+
+	select {
+	case model := <-h.infoChan:
+		return model, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// register is idempotent, repeated calls keep the cluster join time stored in the index for the index entry and the instance
 func (h *cbMembership) register() {
-	ctx, cancel := context.WithTimeout(context.Background(), _timeoutSec*time.Second)
+	h.registerLock.Lock()
+	defer h.registerLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.Timeouts.Register)
 	defer cancel()
 
-	now := time.Now().UnixNano()
+	joinTime := h.clusterJoinTime.Load()
+	if joinTime == 0 {
+		joinTime = time.Now().UnixNano()
+	}
 
-	err := h.createIndex(ctx, now)
+	joinTime, err := h.createIndex(ctx, joinTime)
 	if err != nil {
 		logger.Log.Error("error while create index: %v", err)
 		panic(err)
 	}
 
-	h.clusterJoinTime = now
+	h.clusterJoinTime.Store(joinTime)
+
+	instance := h.newInstance(time.Now().UnixNano())
 
-	instance := Instance{
+	payload, _ := jsoniter.Marshal(instance)
+
+	err = h.store.Upsert(ctx, h.id, payload, h.expirySec)
+	if err != nil {
+		logger.Log.Error("error while register: %v", err)
+		panic(err)
+	}
+}
+
+func (h *cbMembership) newInstance(heartbeatTime int64) *Instance {
+	instance := &Instance{
 		Type:            _type,
-		HeartbeatTime:   now,
-		ClusterJoinTime: now,
+		HeartbeatTime:   heartbeatTime,
+		ClusterJoinTime: h.clusterJoinTime.Load(),
+		Tags:            h.config.Dcp.Group.Membership.Tags,
 	}
 
-	payload, _ := jsoniter.Marshal(instance)
+	// the heartbeat reads the info while the bus listener replaces it
+	if info := h.info.Load(); info != nil {
+		instance.MemberNumber = info.MemberNumber
+	}
+
+	return instance
+}
+
+func (h *cbMembership) GetInstances() []Instance {
+	h.instancesLock.RLock()
+	defer h.instancesLock.RUnlock()
+
+	return append([]Instance{}, h.lastActiveInstances...)
+}
 
-	err = UpdateDocument(ctx, h.client.GetMetaAgent(), h.scopeName, h.collectionName, h.id, payload, _expirySec)
+func (h *cbMembership) GetMemberNumberCollisions() int64 {
+	return atomic.LoadInt64(&h.collisions)
+}
+
+func (h *cbMembership) GetMonitorTickDuration() *helpers.Histogram {
+	return h.monitorTickDuration
+}
+
+// isSteppingBack returns true when another instance with a lower id claims the same member number,
+// heartbeats older than the last assignment can carry a previous member number and are not collisions
+func (h *cbMembership) isSteppingBack(instances []Instance) bool {
+	info := h.info.Load()
+	if info == nil {
+		return false
+	}
 
-	var kvErr *gocbcore.KeyValueError
-	if err != nil && errors.As(err, &kvErr) && kvErr.StatusCode == memd.StatusKeyNotFound {
-		err = CreateDocument(ctx, h.client.GetMetaAgent(), h.scopeName, h.collectionName, h.id, payload, helpers.JSONFlags, _expirySec)
+	selfID := string(h.id)
+	collided := false
 
-		if err == nil {
-			err = UpdateDocument(ctx, h.client.GetMetaAgent(), h.scopeName, h.collectionName, h.id, payload, _expirySec)
+	for _, instance := range instances {
+		if *instance.ID == selfID || instance.MemberNumber != info.MemberNumber || instance.HeartbeatTime <= h.assignedAt {
+			continue
+		}
+
+		collided = true
+
+		if *instance.ID < selfID {
+			logger.Log.Warn(
+				"member number %v is claimed by %v, self = %v steps back",
+				info.MemberNumber, *instance.ID, selfID,
+			)
+			atomic.AddInt64(&h.collisions, 1)
+			return h.config.Dcp.Group.Membership.CollisionPolicy == config.MemberNumberCollisionPolicyStepBack
 		}
 	}
 
-	if err != nil {
-		logger.Log.Error("error while register: %v", err)
-		panic(err)
+	if collided {
+		logger.Log.Warn("member number %v is claimed by another instance, self = %v keeps it", info.MemberNumber, selfID)
+		atomic.AddInt64(&h.collisions, 1)
 	}
+
+	return false
 }
 
-func (h *cbMembership) createIndex(ctx context.Context, clusterJoinTime int64) error {
-	payload, _ := jsoniter.Marshal(clusterJoinTime)
+// stepBack rejoins with a new join time, self moves to the end of the order and the updated index moves it for the other instances too
+func (h *cbMembership) stepBack(instances []Instance) []Instance {
+	joinTime := time.Now().UnixNano()
+	h.clusterJoinTime.Store(joinTime)
+
+	reordered := make([]Instance, 0, len(instances))
 
-	return CreatePath(ctx, h.client.GetMetaAgent(), h.scopeName, h.collectionName, h.instanceAll, h.id, payload, memd.SubdocDocFlagMkDoc)
+	var self *Instance
+	for i := range instances {
+		if *instances[i].ID == string(h.id) {
+			self = &instances[i]
+			continue
+		}
+
+		reordered = append(reordered, instances[i])
+	}
+
+	if self == nil {
+		return instances
+	}
+
+	self.ClusterJoinTime = joinTime
+
+	return append(reordered, *self)
+}
+
+// createIndex returns the join time of the index entry, an existing entry of a previous attempt is kept
+func (h *cbMembership) createIndex(ctx context.Context, clusterJoinTime int64) (int64, error) {
+	compression := h.config.Dcp.Group.Membership.IndexCompression
+
+	if compression == "" {
+		if data, err := h.store.Get(ctx, h.instanceAll); err == nil {
+			if all, err := decodeIndex(data); err == nil {
+				if joinTime, ok := all[string(h.id)]; ok {
+					return joinTime, nil
+				}
+			}
+		}
+
+		payload, _ := jsoniter.Marshal(clusterJoinTime)
+
+		err := h.store.CreatePath(ctx, h.instanceAll, h.id, payload)
+		if !errors.Is(err, gocbcore.ErrDocumentNotJSON) {
+			return clusterJoinTime, err
+		}
+
+		logger.Log.Info("membership index is compressed by another instance, it is rewritten as json")
+	}
+
+	// sub document paths can not be created in a compressed document
+	err := h.store.Modify(ctx, h.instanceAll, func(value []byte) ([]byte, error) {
+		all := map[string]int64{}
+
+		if value != nil {
+			var err error
+			if all, err = decodeIndex(value); err != nil {
+				return nil, err
+			}
+		}
+
+		if joinTime, ok := all[string(h.id)]; ok {
+			clusterJoinTime = joinTime
+		}
+
+		all[string(h.id)] = clusterJoinTime
+
+		return encodeIndex(all, compression)
+	}, 0)
+
+	return clusterJoinTime, err
 }
 
 func (h *cbMembership) isClusterChanged(currentActiveInstances []Instance) bool {
@@ -121,53 +287,115 @@ func (h *cbMembership) isClusterChanged(currentActiveInstances []Instance) bool
 }
 
 func (h *cbMembership) heartbeat() {
-	ctx, cancel := context.WithTimeout(context.Background(), _timeoutSec*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.Timeouts.Heartbeat)
 	defer cancel()
 
-	instance := &Instance{
-		Type:            _type,
-		HeartbeatTime:   time.Now().UnixNano(),
-		ClusterJoinTime: h.clusterJoinTime,
-	}
+	instance := h.newInstance(time.Now().UnixNano())
 
 	payload, _ := jsoniter.Marshal(instance)
 
-	err := UpdateDocument(ctx, h.client.GetMetaAgent(), h.scopeName, h.collectionName, h.id, payload, _expirySec)
+	err := h.store.Update(ctx, h.id, payload, h.expirySec)
 	if err != nil {
 		logger.Log.Error("error while heartbeat: %v", err)
+		h.errorOccurred(err)
 		return
 	}
 }
 
+func (h *cbMembership) errorOccurred(err error) {
+	h.bus.Emit(helpers.ErrorOccurredBusEventName, models.SubsystemError{
+		Subsystem: models.MembershipSubsystem,
+		Err:       err,
+	})
+}
+
 func (h *cbMembership) isAlive(heartbeatTime int64) bool {
 	return (time.Now().UnixNano() - heartbeatTime) < heartbeatTime+(_heartbeatToleranceSec*1000*1000*1000)
 }
 
+// monitorTick warns when a tick takes longer than the interval, the next ticks are delayed and membership detection lags
+func (h *cbMembership) monitorTick() {
+	start := time.Now()
+
+	h.monitor()
+
+	duration := time.Since(start)
+	h.monitorTickDuration.Observe(duration.Seconds())
+
+	if duration > h.monitorInterval {
+		logger.Log.Warn(
+			"membership monitor tick took %v which is longer than the interval %v, the cluster is too large for the interval",
+			duration, h.monitorInterval,
+		)
+	}
+
+	if h.config.Dcp.Group.Membership.MonitorInterval.Adaptive {
+		h.adjustMonitorInterval(len(h.GetInstances()))
+	}
+}
+
+// adjustMonitorInterval scales the interval with the member count to keep the metadata read rate bounded
+func (h *cbMembership) adjustMonitorInterval(members int) {
+	bounds := h.config.Dcp.Group.Membership.MonitorInterval
+
+	interval := bounds.Min * time.Duration(members)
+	if interval < bounds.Min {
+		interval = bounds.Min
+	}
+
+	if interval > bounds.Max {
+		interval = bounds.Max
+	}
+
+	if interval == h.monitorInterval {
+		return
+	}
+
+	logger.Log.Info("membership monitor interval is adjusted from %v to %v for %v members", h.monitorInterval, interval, members)
+
+	h.monitorInterval = interval
+	h.monitorTicker.Reset(interval)
+}
+
 //nolint:funlen
 func (h *cbMembership) monitor() {
-	ctx, cancel := context.WithTimeout(context.Background(), _timeoutSec*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.Timeouts.MonitorRead)
 	defer cancel()
 
-	data, err := Get(ctx, h.client.GetMetaAgent(), h.scopeName, h.collectionName, h.instanceAll)
+	var data []byte
+
+	err := helpers.Retry(func() error {
+		var err error
+		data, err = h.store.Get(ctx, h.instanceAll)
+		return err
+	}, h.config.Dcp.Group.Membership.IndexReadAttempts, _indexReadRetryIntervalMs*time.Millisecond)
 	if err != nil {
 		logger.Log.Error("error while monitor try to get index: %v", err)
+		h.errorOccurred(err)
 		return
 	}
 
-	all := map[string]int64{}
-
-	err = jsoniter.Unmarshal(data, &all)
+	all, err := decodeIndex(data)
 	if err != nil {
 		logger.Log.Error("error while monitor try to unmarshal index: %v", err)
+		h.errorOccurred(err)
 		return
 	}
 
+	if h.config.Dcp.Group.Membership.ReadYourWrites {
+		h.includeSelf(all)
+	}
+
 	ids := make([]string, 0, len(all))
 
 	for k := range all {
 		ids = append(ids, k)
 	}
 	sort.SliceStable(ids, func(i, j int) bool {
+		if all[ids[i]] == all[ids[j]] {
+			return ids[i] < ids[j]
+		}
+
 		return all[ids[i]] < all[ids[j]]
 	})
 
@@ -178,7 +406,7 @@ func (h *cbMembership) monitor() {
 		wg.Add(1)
 		go func(i int, id string) {
 			defer wg.Done()
-			doc, err := Get(ctx, h.client.GetMetaAgent(), h.scopeName, h.collectionName, []byte(id))
+			doc, err := h.store.Get(ctx, []byte(id))
 			var kvErr *gocbcore.KeyValueError
 			if err != nil {
 				if errors.As(err, &kvErr) && kvErr.StatusCode == memd.StatusKeyNotFound {
@@ -214,12 +442,60 @@ func (h *cbMembership) monitor() {
 		}
 	}
 
-	if h.isClusterChanged(filteredInstances) {
+	if !h.hasMinMembers(filteredInstances) {
+		return
+	}
+
+	steppingBack := h.isSteppingBack(filteredInstances)
+	if steppingBack {
+		filteredInstances = h.stepBack(filteredInstances)
+	}
+
+	if h.isClusterChanged(filteredInstances) || steppingBack {
 		h.rebalance(filteredInstances)
 		h.updateIndex(ctx)
 	}
 }
 
+// hasMinMembers holds the rebalance until minMembers are alive or minMembersTimeout is elapsed
+func (h *cbMembership) hasMinMembers(instances []Instance) bool {
+	minMembers := h.config.Dcp.Group.Membership.MinMembers
+	if len(instances) >= minMembers {
+		h.minMembersDeadline = time.Time{}
+		return true
+	}
+
+	if h.minMembersDeadline.IsZero() {
+		h.minMembersDeadline = time.Now().Add(h.config.Dcp.Group.Membership.MinMembersTimeout)
+		logger.Log.Info("waiting for %v members before rebalance, current = %v", minMembers, len(instances))
+		return false
+	}
+
+	if time.Now().Before(h.minMembersDeadline) {
+		return false
+	}
+
+	logger.Log.Warn(
+		"rebalancing with %v members, %v members are not reached in %v",
+		len(instances), minMembers, h.config.Dcp.Group.Membership.MinMembersTimeout,
+	)
+
+	return true
+}
+
+// includeSelf adds the own registration when the index read does not reflect it yet
+func (h *cbMembership) includeSelf(all map[string]int64) {
+	joinTime := h.clusterJoinTime.Load()
+	if joinTime == 0 {
+		return
+	}
+
+	if _, ok := all[string(h.id)]; !ok {
+		logger.Log.Debug("index does not include self yet, self = %v", string(h.id))
+		all[string(h.id)] = joinTime
+	}
+}
+
 func (h *cbMembership) updateIndex(ctx context.Context) {
 	all := map[string]int64{}
 
@@ -227,11 +503,20 @@ func (h *cbMembership) updateIndex(ctx context.Context) {
 		all[*instance.ID] = instance.ClusterJoinTime
 	}
 
-	payload, _ := jsoniter.Marshal(all)
+	compression := h.config.Dcp.Group.Membership.IndexCompression
+
+	payload, err := encodeIndex(all, compression)
+	if err == nil {
+		if compression == "" {
+			err = h.store.Update(ctx, h.instanceAll, payload, 0)
+		} else {
+			err = h.store.Upsert(ctx, h.instanceAll, payload, 0)
+		}
+	}
 
-	err := UpdateDocument(ctx, h.client.GetMetaAgent(), h.scopeName, h.collectionName, h.instanceAll, payload, 0)
 	if err != nil {
 		logger.Log.Error("error while update instances: %v", err)
+		h.errorOccurred(err)
 		return
 	}
 }
@@ -251,53 +536,143 @@ func (h *cbMembership) rebalance(instances []Instance) {
 		logger.Log.Error("error while rebalance, self = %v, err: %v", string(h.id), err)
 		panic(err)
 	} else {
-		h.bus.Emit(helpers.MembershipChangedBusEventName, &membership.Model{
+		h.assignedAt = time.Now().UnixNano()
+
+		model := &membership.Model{
 			MemberNumber: selfOrder,
 			TotalMembers: len(instances),
-		})
+		}
 
+		if model.IsChanged(h.info.Load()) {
+			h.bus.Emit(helpers.MembershipChangedBusEventName, model)
+		}
+
+		h.instancesLock.Lock()
 		h.lastActiveInstances = instances
+		h.instancesLock.Unlock()
 	}
 }
 
 func (h *cbMembership) startHeartbeat() {
-	h.heartbeatTicker = time.NewTicker(_heartbeatIntervalSec * time.Second)
+	h.heartbeatTicker = time.NewTicker(h.heartbeatInterval)
+
+	tracked := h.goroutines.Start("membership-heartbeat")
 
 	go func() {
-		for range h.heartbeatTicker.C {
-			h.heartbeat()
+		defer tracked.Stop()
+
+		for {
+			select {
+			case <-h.heartbeatTicker.C:
+				h.heartbeat()
+				tracked.Touch()
+			case <-h.closeCh:
+				return
+			}
 		}
 	}()
 }
 
 func (h *cbMembership) startMonitor() {
-	h.monitorTicker = time.NewTicker(_monitorIntervalMs * time.Millisecond)
+	h.monitorInterval = h.config.Dcp.Group.Membership.MonitorInterval.Min
+	h.monitorTicker = time.NewTicker(h.monitorInterval)
+
+	tracked := h.goroutines.Start("membership-monitor")
 
 	go func() {
+		defer tracked.Stop()
+
 		logger.Log.Info("couchbase membership will start after %v", h.config.Dcp.Group.Membership.RebalanceDelay)
-		time.Sleep(h.config.Dcp.Group.Membership.RebalanceDelay)
 
-		for range h.monitorTicker.C {
-			h.monitor()
+		select {
+		case <-time.After(h.config.Dcp.Group.Membership.RebalanceDelay):
+		case <-h.closeCh:
+			return
+		}
+
+		for {
+			select {
+			case <-h.monitorTicker.C:
+				h.monitorTick()
+				tracked.Touch()
+			case <-h.closeCh:
+				return
+			}
 		}
 	}()
 }
 
+// fitExpiry adjusts the instance expiry and the heartbeat interval to the max ttl of the metadata collection
+func fitExpiry(maxTTL uint32, policy string) (uint32, time.Duration, error) {
+	if maxTTL == 0 || maxTTL >= _expirySec {
+		return _expirySec, _heartbeatIntervalSec * time.Second, nil
+	}
+
+	if policy == config.MaxTTLPolicyFail {
+		return 0, 0, fmt.Errorf(
+			"metadata collection max ttl %ds is lower than the membership expiry %ds, increase the max ttl or set maxTTLPolicy to %s",
+			maxTTL, _expirySec, config.MaxTTLPolicyAdjust,
+		)
+	}
+
+	heartbeatInterval := time.Duration(maxTTL) * time.Second / 2
+
+	logger.Log.Warn(
+		"metadata collection max ttl %ds is lower than the membership expiry %ds, expiry is adjusted to %ds and heartbeat interval to %v",
+		maxTTL, _expirySec, maxTTL, heartbeatInterval,
+	)
+
+	return maxTTL, heartbeatInterval, nil
+}
+
+// Close releases the pending infoChan sends, the bus listener becomes a no-op since the bus can not unsubscribe concurrently
 func (h *cbMembership) Close() {
 	h.monitorTicker.Stop()
 	h.heartbeatTicker.Stop()
+
+	h.closeLock.Lock()
+	if !h.isClosed() {
+		close(h.closeCh)
+	}
+	h.closeLock.Unlock()
+
+	h.infoSenders.Wait()
+}
+
+func (h *cbMembership) isClosed() bool {
+	select {
+	case <-h.closeCh:
+		return true
+	default:
+		return false
+	}
 }
 
 func (h *cbMembership) membershipChangedListener(event interface{}) {
+	// infoSenders can not be added while Close waits for them
+	h.closeLock.Lock()
+	defer h.closeLock.Unlock()
+
+	if h.isClosed() {
+		return
+	}
+
 	model := event.(*membership.Model)
 
-	h.info = model
+	h.info.Store(model)
+
+	h.infoSenders.Add(1)
 	go func() {
-		h.infoChan <- model
+		defer h.infoSenders.Done()
+
+		select {
+		case h.infoChan <- model:
+		case <-h.closeCh:
+		}
 	}()
 }
 
-func NewCBMembership(config *config.Dcp, client Client, bus helpers.Bus) membership.Membership {
+func NewCBMembership(config *config.Dcp, client Client, bus helpers.Bus, goroutines *helpers.Goroutines) membership.Membership {
 	if !config.IsCouchbaseMetadata() {
 		err := errors.New("unsupported metadata type")
 		logger.Log.Error("cannot initialize couchbase membership, err: %v", err)
@@ -307,14 +682,34 @@ func NewCBMembership(config *config.Dcp, client Client, bus helpers.Bus) members
 	_, scope, collection, _, _ := config.GetCouchbaseMetadata()
 
 	cbm := &cbMembership{
-		infoChan:       make(chan *membership.Model),
-		client:         client,
-		id:             []byte(helpers.Prefix + config.Dcp.Group.Name + ":" + _type + ":" + uuid.New().String()),
-		instanceAll:    []byte(helpers.Prefix + config.Dcp.Group.Name + ":" + _type + ":all"),
-		bus:            bus,
-		scopeName:      scope,
-		collectionName: collection,
-		config:         config,
+		infoChan:            make(chan *membership.Model),
+		closeCh:             make(chan struct{}),
+		monitorTickDuration: helpers.NewHistogram(_monitorTickDurationBuckets),
+		client:              client,
+		id:                  []byte(helpers.Prefix + config.Dcp.Group.Name + ":" + _type + ":" + uuid.New().String()),
+		instanceAll:         []byte(helpers.Prefix + config.Dcp.Group.Name + ":" + _type + ":all"),
+		bus:                 bus,
+		goroutines:          goroutines,
+		scopeName:           scope,
+		collectionName:      collection,
+		config:              config,
+		store: &cbMembershipStore{
+			client:         client,
+			scopeName:      scope,
+			collectionName: collection,
+		},
+	}
+
+	maxTTL, err := client.GetMetaCollectionMaxTTL(scope, collection)
+	if err != nil {
+		logger.Log.Warn("cannot get metadata collection max ttl, membership expiry is not adjusted, err: %v", err)
+		maxTTL = 0
+	}
+
+	cbm.expirySec, cbm.heartbeatInterval, err = fitExpiry(maxTTL, config.Dcp.Group.Membership.MaxTTLPolicy)
+	if err != nil {
+		logger.Log.Error("cannot start couchbase membership, err: %v", err)
+		panic(err)
 	}
 
 	cbm.register()
@@ -0,0 +1,126 @@
+package couchbase
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Trendyol/go-dcp/helpers"
+
+	"github.com/couchbase/gocbcore/v10"
+	"github.com/couchbase/gocbcore/v10/memd"
+)
+
+type membershipStore interface {
+	Get(ctx context.Context, id []byte) ([]byte, error)
+	Update(ctx context.Context, id []byte, value []byte, expiry uint32) error
+	Upsert(ctx context.Context, id []byte, value []byte, expiry uint32) error
+	Modify(ctx context.Context, id []byte, modify func(value []byte) ([]byte, error), expiry uint32) error
+	CreatePath(ctx context.Context, id []byte, path []byte, value []byte) error
+}
+
+type cbMembershipStore struct {
+	client         Client
+	scopeName      string
+	collectionName string
+}
+
+func (s *cbMembershipStore) Get(ctx context.Context, id []byte) ([]byte, error) {
+	return Get(ctx, s.client.GetMetaAgent(), s.scopeName, s.collectionName, id)
+}
+
+func (s *cbMembershipStore) Update(ctx context.Context, id []byte, value []byte, expiry uint32) error {
+	return UpdateDocument(ctx, s.client.GetMetaAgent(), s.scopeName, s.collectionName, id, value, expiry)
+}
+
+// Upsert inserts the document or replaces it with its cas, concurrent writers are retried until one value wins
+func (s *cbMembershipStore) Upsert(ctx context.Context, id []byte, value []byte, expiry uint32) error {
+	return s.Modify(ctx, id, func(_ []byte) ([]byte, error) {
+		return value, nil
+	}, expiry)
+}
+
+// Modify writes the modified value with the cas of the read value, modify gets nil if the document does not exist
+func (s *cbMembershipStore) Modify(ctx context.Context, id []byte, modify func(value []byte) ([]byte, error), expiry uint32) error {
+	return modifyDocument(ctx, &agentDocuments{
+		agent:          s.client.GetMetaAgent(),
+		scopeName:      s.scopeName,
+		collectionName: s.collectionName,
+	}, id, modify, expiry)
+}
+
+// casDocuments are the document operations of modifyDocument
+type casDocuments interface {
+	GetWithCas(ctx context.Context, id []byte) ([]byte, gocbcore.Cas, error)
+	Insert(ctx context.Context, id []byte, value []byte, flags uint32, expiry uint32) error
+	Replace(ctx context.Context, id []byte, value []byte, flags uint32, expiry uint32, cas gocbcore.Cas) error
+}
+
+type agentDocuments struct {
+	agent          *gocbcore.Agent
+	scopeName      string
+	collectionName string
+}
+
+func (d *agentDocuments) GetWithCas(ctx context.Context, id []byte) ([]byte, gocbcore.Cas, error) {
+	return GetWithCas(ctx, d.agent, d.scopeName, d.collectionName, id)
+}
+
+func (d *agentDocuments) Insert(ctx context.Context, id []byte, value []byte, flags uint32, expiry uint32) error {
+	return InsertDocument(ctx, d.agent, d.scopeName, d.collectionName, id, value, flags, expiry)
+}
+
+func (d *agentDocuments) Replace(ctx context.Context, id []byte, value []byte, flags uint32, expiry uint32, cas gocbcore.Cas) error {
+	return ReplaceDocument(ctx, d.agent, d.scopeName, d.collectionName, id, value, flags, expiry, cas)
+}
+
+// modifyDocument retries the read and the write while concurrent writers change the document in between
+func modifyDocument(ctx context.Context,
+	documents casDocuments,
+	id []byte,
+	modify func(value []byte) ([]byte, error),
+	expiry uint32,
+) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		current, cas, err := documents.GetWithCas(ctx, id)
+		if err != nil && !isKeyNotFoundError(err) {
+			return err
+		}
+
+		notFound := err != nil
+		if notFound {
+			current = nil
+		}
+
+		value, err := modify(current)
+		if err != nil {
+			return err
+		}
+
+		flags := helpers.JSONFlags
+		if isCompressedIndex(value) {
+			flags = helpers.BinaryFlags
+		}
+
+		if notFound {
+			err = documents.Insert(ctx, id, value, flags, expiry)
+			if errors.Is(err, gocbcore.ErrDocumentExists) {
+				continue
+			}
+		} else {
+			err = documents.Replace(ctx, id, value, flags, expiry, cas)
+			if errors.Is(err, gocbcore.ErrCasMismatch) || errors.Is(err, gocbcore.ErrDocumentNotFound) {
+				continue
+			}
+		}
+
+		return err
+	}
+}
+
+func (s *cbMembershipStore) CreatePath(ctx context.Context, id []byte, path []byte, value []byte) error {
+	return CreatePath(ctx, s.client.GetMetaAgent(), s.scopeName, s.collectionName, id, path, value, memd.SubdocDocFlagMkDoc)
+}
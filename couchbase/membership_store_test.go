@@ -0,0 +1,98 @@
+package couchbase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/couchbase/gocbcore/v10"
+	"github.com/couchbase/gocbcore/v10/memd"
+)
+
+// racingDocuments is changed by another writer between the read and the write of the first attempts
+type racingDocuments struct {
+	value       []byte
+	cas         gocbcore.Cas
+	gets        int
+	insertRaces int
+	replaceRace int
+}
+
+func (d *racingDocuments) GetWithCas(_ context.Context, _ []byte) ([]byte, gocbcore.Cas, error) {
+	d.gets++
+
+	if d.value == nil {
+		return nil, 0, &gocbcore.KeyValueError{InnerError: gocbcore.ErrDocumentNotFound, StatusCode: memd.StatusKeyNotFound}
+	}
+
+	return d.value, d.cas, nil
+}
+
+func (d *racingDocuments) Insert(_ context.Context, _ []byte, value []byte, _ uint32, _ uint32) error {
+	if d.insertRaces > 0 {
+		d.insertRaces--
+		d.value, d.cas = []byte(`{"other":1}`), d.cas+1
+		return gocbcore.ErrDocumentExists
+	}
+
+	d.value, d.cas = value, d.cas+1
+
+	return nil
+}
+
+func (d *racingDocuments) Replace(_ context.Context, _ []byte, value []byte, _ uint32, _ uint32, _ gocbcore.Cas) error {
+	if d.replaceRace > 0 {
+		d.replaceRace--
+		d.cas++
+		return gocbcore.ErrCasMismatch
+	}
+
+	d.value, d.cas = value, d.cas+1
+
+	return nil
+}
+
+func TestModifyDocument_RetriesConcurrentWrites(t *testing.T) {
+	documents := &racingDocuments{insertRaces: 1, replaceRace: 1}
+
+	var seen [][]byte
+
+	err := modifyDocument(context.Background(), documents, []byte("all"), func(value []byte) ([]byte, error) {
+		seen = append(seen, value)
+		return []byte(`{"self":2}`), nil
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if documents.gets != 3 {
+		t.Errorf("document is read %v times, want 3 after an insert and a cas race", documents.gets)
+	}
+
+	if len(seen) != 3 || seen[0] != nil || string(seen[1]) != `{"other":1}` {
+		t.Errorf("modify is called with %q, want nil and then the concurrent value", seen)
+	}
+
+	if string(documents.value) != `{"self":2}` {
+		t.Errorf("value = %s, want the modified one", documents.value)
+	}
+}
+
+func TestModifyDocument_StopsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	documents := &racingDocuments{}
+
+	err := modifyDocument(ctx, documents, []byte("all"), func(value []byte) ([]byte, error) {
+		return value, nil
+	}, 0)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want %v", err, context.Canceled)
+	}
+
+	if documents.gets != 0 {
+		t.Errorf("document is read %v times after cancel", documents.gets)
+	}
+}
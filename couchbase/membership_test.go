@@ -0,0 +1,774 @@
+package couchbase
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Trendyol/go-dcp/config"
+	"github.com/Trendyol/go-dcp/helpers"
+	"github.com/Trendyol/go-dcp/logger"
+	"github.com/Trendyol/go-dcp/membership"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/couchbase/gocbcore/v10"
+)
+
+func TestCBMembership_GetInfoContextReturnsTimeoutWhenNoModelArrives(t *testing.T) {
+	h := &cbMembership{
+		infoChan: make(chan *membership.Model),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	model, err := h.GetInfoContext(ctx)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if model != nil {
+		t.Errorf("model = %v, want nil", model)
+	}
+}
+
+func TestCBMembership_GetInfoContextReturnsReceivedModel(t *testing.T) {
+	h := &cbMembership{
+		infoChan: make(chan *membership.Model),
+	}
+
+	h.membershipChangedListener(&membership.Model{MemberNumber: 2, TotalMembers: 3})
+
+	model, err := h.GetInfoContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if model.MemberNumber != 2 || model.TotalMembers != 3 {
+		t.Errorf("model = %v", model)
+	}
+}
+
+func TestCBMembership_CloseReleasesPendingInfoSends(t *testing.T) {
+	h := &cbMembership{
+		infoChan:        make(chan *membership.Model),
+		closeCh:         make(chan struct{}),
+		monitorTicker:   time.NewTicker(time.Hour),
+		heartbeatTicker: time.NewTicker(time.Hour),
+	}
+
+	baseline := runtime.NumGoroutine()
+
+	for i := 1; i <= 3; i++ {
+		h.membershipChangedListener(&membership.Model{MemberNumber: i, TotalMembers: 3})
+	}
+
+	h.Close()
+	h.membershipChangedListener(&membership.Model{MemberNumber: 1, TotalMembers: 1})
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if n := runtime.NumGoroutine(); n > baseline {
+		t.Errorf("goroutines = %v, want at most %v after close", n, baseline)
+	}
+
+	if info := h.info.Load(); info.TotalMembers != 3 {
+		t.Errorf("info = %v, changes after close must be ignored", info)
+	}
+}
+
+func TestCBMembership_GoroutinesAreStoppedAfterClose(t *testing.T) {
+	c := &config.Dcp{Logging: config.Logging{Level: logger.ERROR}}
+	c.ApplyDefaults()
+
+	goroutines := helpers.NewGoroutines()
+
+	h := &cbMembership{
+		config:            c,
+		infoChan:          make(chan *membership.Model),
+		closeCh:           make(chan struct{}),
+		heartbeatInterval: time.Hour,
+		goroutines:        goroutines,
+	}
+
+	h.startHeartbeat()
+	h.startMonitor()
+
+	assertStates := func(want string) {
+		t.Helper()
+
+		states := goroutines.Get()
+		if len(states) != 2 || states[0].Name != "membership-heartbeat" || states[1].Name != "membership-monitor" {
+			t.Fatalf("goroutines = %v, want the heartbeat and the monitor", states)
+		}
+
+		for _, state := range states {
+			if state.State != want {
+				t.Errorf("%v is %v, want %v", state.Name, state.State, want)
+			}
+		}
+	}
+
+	assertStates(helpers.GoroutineRunning)
+
+	h.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if states := goroutines.Get(); states[0].State == helpers.GoroutineStopped && states[1].State == helpers.GoroutineStopped {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assertStates(helpers.GoroutineStopped)
+}
+
+func TestCBMembership_InstanceTagsRoundTrip(t *testing.T) {
+	c := &config.Dcp{}
+	c.Dcp.Group.Membership.Tags = map[string]string{"zone": "eu-west-1a"}
+
+	h := &cbMembership{config: c}
+	h.clusterJoinTime.Store(1)
+
+	payload, err := jsoniter.Marshal(h.newInstance(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instance := &Instance{}
+	if err := jsoniter.Unmarshal(payload, instance); err != nil {
+		t.Fatal(err)
+	}
+
+	if instance.Tags["zone"] != "eu-west-1a" {
+		t.Errorf("tags = %v", instance.Tags)
+	}
+
+	if instance.ClusterJoinTime != 1 || instance.HeartbeatTime != 2 {
+		t.Errorf("instance = %v", instance)
+	}
+}
+
+type fakeMembershipStore struct {
+	docs     map[string][]byte
+	failures map[string]int
+	reads    map[string]int
+	updates  map[string][]byte
+	paths    map[string]map[string][]byte
+	delay    time.Duration
+	timeout  time.Duration
+	lock     sync.Mutex
+}
+
+// recordTimeout keeps the remaining time of the operation context
+func (s *fakeMembershipStore) recordTimeout(ctx context.Context) {
+	if deadline, ok := ctx.Deadline(); ok {
+		s.timeout = time.Until(deadline)
+	}
+}
+
+func (s *fakeMembershipStore) Get(ctx context.Context, id []byte) ([]byte, error) {
+	time.Sleep(s.delay)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.recordTimeout(ctx)
+
+	s.reads[string(id)]++
+
+	if s.failures[string(id)] > 0 {
+		s.failures[string(id)]--
+		return nil, errors.New("temporary failure")
+	}
+
+	return s.docs[string(id)], nil
+}
+
+func (s *fakeMembershipStore) Upsert(ctx context.Context, id []byte, value []byte, expiry uint32) error {
+	return s.Update(ctx, id, value, expiry)
+}
+
+func (s *fakeMembershipStore) Update(ctx context.Context, id []byte, value []byte, _ uint32) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.recordTimeout(ctx)
+
+	s.updates[string(id)] = value
+	s.docs[string(id)] = value
+
+	return nil
+}
+
+func (s *fakeMembershipStore) Modify(ctx context.Context, id []byte, modify func(value []byte) ([]byte, error), _ uint32) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.recordTimeout(ctx)
+
+	value, err := modify(s.docs[string(id)])
+	if err != nil {
+		return err
+	}
+
+	s.updates[string(id)] = value
+	s.docs[string(id)] = value
+
+	return nil
+}
+
+// CreatePath is not visible to the next reads, like a lagging index
+func (s *fakeMembershipStore) CreatePath(ctx context.Context, id []byte, path []byte, value []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.recordTimeout(ctx)
+
+	if isCompressedIndex(s.docs[string(id)]) {
+		return gocbcore.ErrDocumentNotJSON
+	}
+
+	s.updates[string(id)] = value
+
+	if s.paths == nil {
+		s.paths = map[string]map[string][]byte{}
+	}
+
+	if s.paths[string(id)] == nil {
+		s.paths[string(id)] = map[string][]byte{}
+	}
+
+	s.paths[string(id)][string(path)] = value
+
+	return nil
+}
+
+func TestCBMembership_MonitorRetriesIndexRead(t *testing.T) {
+	c := &config.Dcp{}
+	c.ApplyDefaults()
+
+	bus := helpers.NewBus()
+
+	var received *membership.Model
+	bus.Subscribe(helpers.MembershipChangedBusEventName, func(event interface{}) {
+		received = event.(*membership.Model)
+	})
+
+	index, _ := jsoniter.Marshal(map[string]int64{"self": 1})
+	instance, _ := jsoniter.Marshal(&Instance{Type: _type, HeartbeatTime: time.Now().UnixNano(), ClusterJoinTime: 1})
+
+	store := &fakeMembershipStore{
+		docs:     map[string][]byte{"all": index, "self": instance},
+		failures: map[string]int{"all": 1},
+		reads:    map[string]int{},
+		updates:  map[string][]byte{},
+	}
+
+	h := &cbMembership{
+		id:          []byte("self"),
+		instanceAll: []byte("all"),
+		config:      c,
+		bus:         bus,
+		store:       store,
+	}
+
+	h.monitor()
+
+	if store.reads["all"] != 2 {
+		t.Errorf("index is read %v times, want 2", store.reads["all"])
+	}
+
+	if received == nil || received.MemberNumber != 1 || received.TotalMembers != 1 {
+		t.Fatalf("membership changed event = %v", received)
+	}
+
+	if _, ok := store.updates["all"]; !ok {
+		t.Errorf("index is not updated")
+	}
+}
+
+func TestCBMembership_FirstMonitorReadIncludesSelfWithReadYourWrites(t *testing.T) {
+	c := &config.Dcp{}
+	c.Dcp.Group.Membership.ReadYourWrites = true
+	c.ApplyDefaults()
+
+	bus := helpers.NewBus()
+
+	var received *membership.Model
+	bus.Subscribe(helpers.MembershipChangedBusEventName, func(event interface{}) {
+		received = event.(*membership.Model)
+	})
+
+	index, _ := jsoniter.Marshal(map[string]int64{})
+
+	store := &fakeMembershipStore{
+		docs:     map[string][]byte{"all": index},
+		failures: map[string]int{},
+		reads:    map[string]int{},
+		updates:  map[string][]byte{},
+	}
+
+	h := &cbMembership{
+		id:          []byte("self"),
+		instanceAll: []byte("all"),
+		config:      c,
+		bus:         bus,
+		store:       store,
+	}
+
+	h.register()
+	h.monitor()
+
+	if received == nil || received.MemberNumber != 1 || received.TotalMembers != 1 {
+		t.Fatalf("membership changed event = %v", received)
+	}
+
+	all := map[string]int64{}
+	if err := jsoniter.Unmarshal(store.docs["all"], &all); err != nil {
+		t.Fatal(err)
+	}
+
+	if all["self"] != h.clusterJoinTime.Load() {
+		t.Errorf("index = %v, want self", all)
+	}
+}
+
+func TestCBMembership_MemberNumberCollisionIsResolvedByLowestID(t *testing.T) {
+	c := &config.Dcp{}
+	c.ApplyDefaults()
+
+	now := time.Now().UnixNano()
+
+	a, _ := jsoniter.Marshal(&Instance{Type: _type, HeartbeatTime: now, ClusterJoinTime: 1, MemberNumber: 1})
+	b, _ := jsoniter.Marshal(&Instance{Type: _type, HeartbeatTime: now, ClusterJoinTime: 1, MemberNumber: 1})
+
+	// the index replicas disagree, both instances see themselves first and claim member number 1
+	newMember := func(id string, index map[string]int64) *cbMembership {
+		all, _ := jsoniter.Marshal(index)
+
+		bus := helpers.NewBus()
+
+		aID, bID := "a", "b"
+
+		h := &cbMembership{
+			id:                  []byte(id),
+			instanceAll:         []byte("all"),
+			config:              c,
+			bus:                 bus,
+			lastActiveInstances: []Instance{{ID: &aID}, {ID: &bID}},
+			store: &fakeMembershipStore{
+				docs:     map[string][]byte{"all": all, "a": a, "b": b},
+				failures: map[string]int{},
+				reads:    map[string]int{},
+				updates:  map[string][]byte{},
+			},
+		}
+		h.info.Store(&membership.Model{MemberNumber: 1, TotalMembers: 2})
+
+		bus.Subscribe(helpers.MembershipChangedBusEventName, func(event interface{}) {
+			h.info.Store(event.(*membership.Model))
+		})
+
+		return h
+	}
+
+	winner := newMember("a", map[string]int64{"a": 1, "b": 2})
+	loser := newMember("b", map[string]int64{"a": 2, "b": 1})
+
+	for i := 0; i < 2; i++ {
+		winner.monitor()
+		loser.monitor()
+	}
+
+	// the winner counts the collision until the loser heartbeats its new member number
+	if winner.GetMemberNumberCollisions() == 0 || loser.GetMemberNumberCollisions() != 1 {
+		t.Errorf("collisions = %v, %v, want both counted and the loser once", winner.GetMemberNumberCollisions(), loser.GetMemberNumberCollisions())
+	}
+
+	winnerInfo, loserInfo := winner.info.Load(), loser.info.Load()
+
+	if winnerInfo.MemberNumber != 1 || loserInfo.MemberNumber != 2 || loserInfo.TotalMembers != 2 {
+		t.Fatalf("member numbers = %v, %v, want 1, 2", winnerInfo, loserInfo)
+	}
+
+	all := map[string]int64{}
+	if err := jsoniter.Unmarshal(loser.store.(*fakeMembershipStore).docs["all"], &all); err != nil {
+		t.Fatal(err)
+	}
+
+	if all["b"] != loser.clusterJoinTime.Load() || all["b"] <= all["a"] {
+		t.Errorf("index = %v, the loser must rejoin after the winner", all)
+	}
+}
+
+func TestCBMembership_SteppingBackToTheSameModelIsNotReemitted(t *testing.T) {
+	c := &config.Dcp{}
+	c.ApplyDefaults()
+
+	index, _ := jsoniter.Marshal(map[string]int64{"a": 1, "b": 1})
+	// a still heartbeats the member number it had before the latest assignment
+	a, _ := jsoniter.Marshal(&Instance{Type: _type, HeartbeatTime: time.Now().UnixNano(), ClusterJoinTime: 1, MemberNumber: 2})
+	b, _ := jsoniter.Marshal(&Instance{Type: _type, HeartbeatTime: time.Now().UnixNano(), ClusterJoinTime: 1, MemberNumber: 2})
+
+	bus := helpers.NewBus()
+
+	var received []*membership.Model
+	bus.Subscribe(helpers.MembershipChangedBusEventName, func(event interface{}) {
+		received = append(received, event.(*membership.Model))
+	})
+
+	aID, bID := "a", "b"
+
+	h := &cbMembership{
+		id:                  []byte("b"),
+		instanceAll:         []byte("all"),
+		config:              c,
+		bus:                 bus,
+		lastActiveInstances: []Instance{{ID: &aID}, {ID: &bID}},
+		store: &fakeMembershipStore{
+			docs:     map[string][]byte{"all": index, "a": a, "b": b},
+			failures: map[string]int{},
+			reads:    map[string]int{},
+			updates:  map[string][]byte{},
+		},
+	}
+	h.info.Store(&membership.Model{MemberNumber: 2, TotalMembers: 2})
+
+	h.monitor()
+
+	if h.GetMemberNumberCollisions() != 1 {
+		t.Errorf("collisions = %v, want 1", h.GetMemberNumberCollisions())
+	}
+
+	if len(received) != 0 {
+		t.Errorf("recomputed model equals the current one, got %v", received)
+	}
+
+	for i := 0; i < 3; i++ {
+		h.monitor()
+	}
+
+	if h.GetMemberNumberCollisions() != 1 {
+		t.Errorf("heartbeats older than the assignment must not collide, collisions = %v", h.GetMemberNumberCollisions())
+	}
+}
+
+func TestFitExpiry_AdjustsToCollectionMaxTTL(t *testing.T) {
+	expirySec, heartbeatInterval, err := fitExpiry(5, config.MaxTTLPolicyAdjust)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expirySec != 5 {
+		t.Errorf("expiry = %vs, want 5s", expirySec)
+	}
+
+	if heartbeatInterval != 2500*time.Millisecond {
+		t.Errorf("heartbeat interval = %v, want 2.5s", heartbeatInterval)
+	}
+
+	if _, _, err := fitExpiry(5, config.MaxTTLPolicyFail); err == nil {
+		t.Errorf("fail policy must return an error")
+	}
+
+	for _, maxTTL := range []uint32{0, 60} {
+		expirySec, heartbeatInterval, err := fitExpiry(maxTTL, config.MaxTTLPolicyFail)
+		if err != nil || expirySec != _expirySec || heartbeatInterval != _heartbeatIntervalSec*time.Second {
+			t.Errorf("max ttl %vs must keep the defaults, got %vs, %v, %v", maxTTL, expirySec, heartbeatInterval, err)
+		}
+	}
+}
+
+func TestCBMembership_RebalanceWaitsForMinMembers(t *testing.T) {
+	c := &config.Dcp{}
+	c.Dcp.Group.Membership.MinMembers = 2
+	c.Dcp.Group.Membership.MinMembersTimeout = 100 * time.Millisecond
+	c.ApplyDefaults()
+
+	newMembership := func(ids ...string) (*cbMembership, *[]*membership.Model) {
+		bus := helpers.NewBus()
+
+		var received []*membership.Model
+		bus.Subscribe(helpers.MembershipChangedBusEventName, func(event interface{}) {
+			received = append(received, event.(*membership.Model))
+		})
+
+		all := map[string]int64{}
+		docs := map[string][]byte{}
+
+		for i, id := range ids {
+			all[id] = int64(i + 1)
+			docs[id], _ = jsoniter.Marshal(Instance{Type: _type, HeartbeatTime: time.Now().UnixNano(), ClusterJoinTime: int64(i + 1)})
+		}
+
+		docs["all"], _ = jsoniter.Marshal(all)
+
+		return &cbMembership{
+			id:          []byte("self"),
+			instanceAll: []byte("all"),
+			config:      c,
+			bus:         bus,
+			store: &fakeMembershipStore{
+				docs:     docs,
+				failures: map[string]int{},
+				reads:    map[string]int{},
+				updates:  map[string][]byte{},
+			},
+		}, &received
+	}
+
+	h, received := newMembership("self")
+
+	h.monitor()
+	h.monitor()
+
+	if len(*received) != 0 {
+		t.Fatalf("rebalance must wait for min members, received = %v", *received)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	h.monitor()
+
+	if len(*received) != 1 || (*received)[0].TotalMembers != 1 {
+		t.Fatalf("rebalance must happen after min members timeout, received = %v", *received)
+	}
+
+	h, received = newMembership("self", "other")
+
+	h.monitor()
+
+	if len(*received) != 1 || (*received)[0].TotalMembers != 2 {
+		t.Fatalf("rebalance must happen when min members are reached, received = %v", *received)
+	}
+}
+
+func TestCBMembership_RegisterIsIdempotent(t *testing.T) {
+	c := &config.Dcp{}
+	c.ApplyDefaults()
+
+	store := &fakeMembershipStore{
+		docs:     map[string][]byte{},
+		failures: map[string]int{},
+		reads:    map[string]int{},
+		updates:  map[string][]byte{},
+	}
+
+	h := &cbMembership{
+		id:          []byte("self"),
+		instanceAll: []byte("all"),
+		config:      c,
+		bus:         helpers.NewBus(),
+		store:       store,
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.register()
+		}()
+	}
+	wg.Wait()
+
+	if len(store.docs) != 1 {
+		t.Fatalf("docs = %v, want only the instance document", store.docs)
+	}
+
+	instance := Instance{}
+	if err := jsoniter.Unmarshal(store.docs["self"], &instance); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.paths["all"]) != 1 {
+		t.Fatalf("index entries = %v, want only self", store.paths["all"])
+	}
+
+	var joinTime int64
+	if err := jsoniter.Unmarshal(store.paths["all"]["self"], &joinTime); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := h.clusterJoinTime.Load(); instance.ClusterJoinTime != want || joinTime != want {
+		t.Errorf("instance join time = %v, index join time = %v, want %v", instance.ClusterJoinTime, joinTime, want)
+	}
+}
+
+func TestCBMembership_RegisterKeepsJoinTimeOfStoredIndexEntry(t *testing.T) {
+	c := &config.Dcp{}
+	c.ApplyDefaults()
+
+	// left by a previous attempt which timed out after the index write
+	index, _ := jsoniter.Marshal(map[string]int64{"other": 3, "self": 7})
+
+	store := &fakeMembershipStore{
+		docs:     map[string][]byte{"all": index},
+		failures: map[string]int{},
+		reads:    map[string]int{},
+		updates:  map[string][]byte{},
+	}
+
+	h := &cbMembership{
+		id:          []byte("self"),
+		instanceAll: []byte("all"),
+		config:      c,
+		bus:         helpers.NewBus(),
+		store:       store,
+	}
+
+	h.register()
+
+	if joinTime := h.clusterJoinTime.Load(); joinTime != 7 {
+		t.Errorf("join time = %v, want the stored 7", joinTime)
+	}
+
+	if len(store.paths["all"]) != 0 {
+		t.Errorf("index entries = %v, the stored entry must not be rewritten", store.paths["all"])
+	}
+
+	instance := Instance{}
+	if err := jsoniter.Unmarshal(store.docs["self"], &instance); err != nil {
+		t.Fatal(err)
+	}
+
+	if instance.ClusterJoinTime != 7 {
+		t.Errorf("instance join time = %v, want 7", instance.ClusterJoinTime)
+	}
+}
+
+type warnRecorder struct {
+	logger.Logger
+	warnings []string
+	lock     sync.Mutex
+}
+
+func (l *warnRecorder) Warn(message string, _ ...interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.warnings = append(l.warnings, message)
+}
+
+func TestCBMembership_SlowMonitorTickIsObservedAndWarned(t *testing.T) {
+	c := &config.Dcp{}
+	c.ApplyDefaults()
+
+	recorder := &warnRecorder{Logger: logger.Log}
+	previous := logger.Log
+	logger.Log = recorder
+	defer func() { logger.Log = previous }()
+
+	index, _ := jsoniter.Marshal(map[string]int64{})
+
+	h := &cbMembership{
+		id:                  []byte("self"),
+		instanceAll:         []byte("all"),
+		config:              c,
+		bus:                 helpers.NewBus(),
+		monitorTickDuration: helpers.NewHistogram(_monitorTickDurationBuckets),
+		monitorInterval:     500 * time.Millisecond,
+		store: &fakeMembershipStore{
+			docs:     map[string][]byte{"all": index},
+			failures: map[string]int{},
+			reads:    map[string]int{},
+			updates:  map[string][]byte{},
+			delay:    500 * time.Millisecond,
+		},
+	}
+
+	h.monitorTick()
+
+	count, sum, buckets := h.GetMonitorTickDuration().Snapshot()
+
+	if count != 1 || sum < 0.5 {
+		t.Errorf("count = %v, sum = %v, want a single tick longer than 0.5s", count, sum)
+	}
+
+	if buckets[0.5] != 0 || buckets[1] != 1 {
+		t.Errorf("buckets = %v, want the tick between 0.5s and 1s", buckets)
+	}
+
+	if len(recorder.warnings) != 1 {
+		t.Errorf("warnings = %v, want a slow tick warning", recorder.warnings)
+	}
+}
+
+func TestCBMembership_OperationsUseTheirTimeouts(t *testing.T) {
+	c := &config.Dcp{Timeouts: config.Timeouts{Register: time.Second, Heartbeat: 2 * time.Second, MonitorRead: 3 * time.Second}}
+	c.ApplyDefaults()
+
+	index, _ := jsoniter.Marshal(map[string]int64{})
+
+	store := &fakeMembershipStore{
+		docs:     map[string][]byte{"all": index},
+		failures: map[string]int{},
+		reads:    map[string]int{},
+		updates:  map[string][]byte{},
+	}
+
+	h := &cbMembership{
+		id:          []byte("self"),
+		instanceAll: []byte("all"),
+		config:      c,
+		bus:         helpers.NewBus(),
+		store:       store,
+	}
+
+	for name, operation := range map[string]struct {
+		run     func()
+		timeout time.Duration
+	}{
+		"register":    {run: h.register, timeout: time.Second},
+		"heartbeat":   {run: h.heartbeat, timeout: 2 * time.Second},
+		"monitorRead": {run: h.monitor, timeout: 3 * time.Second},
+	} {
+		operation.run()
+
+		if store.timeout > operation.timeout || store.timeout < operation.timeout-500*time.Millisecond {
+			t.Errorf("%v timeout = %v, want %v", name, store.timeout, operation.timeout)
+		}
+	}
+}
+
+func TestCBMembership_AdaptiveMonitorIntervalGrowsWithinBounds(t *testing.T) {
+	c := &config.Dcp{}
+	c.Dcp.Group.Membership.MonitorInterval = config.DCPMonitorInterval{Adaptive: true, Min: 100 * time.Millisecond, Max: time.Second}
+	c.ApplyDefaults()
+
+	h := &cbMembership{
+		config:          c,
+		monitorInterval: 100 * time.Millisecond,
+		monitorTicker:   time.NewTicker(100 * time.Millisecond),
+	}
+	defer h.monitorTicker.Stop()
+
+	previous := h.monitorInterval
+
+	for _, members := range []int{1, 2, 5, 10, 50} {
+		h.adjustMonitorInterval(members)
+
+		if h.monitorInterval < previous {
+			t.Errorf("%v members: interval = %v, must not decrease from %v", members, h.monitorInterval, previous)
+		}
+
+		if h.monitorInterval < 100*time.Millisecond || h.monitorInterval > time.Second {
+			t.Errorf("%v members: interval = %v, want within bounds", members, h.monitorInterval)
+		}
+
+		previous = h.monitorInterval
+	}
+
+	if h.monitorInterval != time.Second {
+		t.Errorf("interval = %v, want max for a large cluster", h.monitorInterval)
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"errors"
 	"strconv"
-	"sync"
+	"sync/atomic"
 
 	"github.com/Trendyol/go-dcp/wrapper"
 
@@ -25,13 +25,15 @@ import (
 
 type cbMetadata struct {
 	client         Client
+	getCheckpoint  func(ctx context.Context, id []byte) ([]byte, error)
+	saveCheckpoint func(ctx context.Context, id []byte, payload []byte) error
 	config         *config.Dcp
 	scopeName      string
 	collectionName string
 }
 
 func (s *cbMetadata) Save(state map[uint16]*models.CheckpointDocument, dirtyOffsets map[uint16]bool, _ string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), s.config.Checkpoint.Timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.Timeouts.CheckpointSave)
 	defer cancel()
 
 	eg, _ := errgroup.WithContext(ctx)
@@ -48,18 +50,22 @@ func (s *cbMetadata) saveVBucketCheckpoint(ctx context.Context, vbID uint16, che
 	return func() error {
 		id := getCheckpointID(vbID, s.config.Dcp.Group.Name)
 		payload, _ := jsoniter.Marshal(checkpointDocument)
-		err := UpsertXattrs(ctx, s.client.GetMetaAgent(), s.scopeName, s.collectionName, id, helpers.Name, payload, 0)
+		return s.saveCheckpoint(ctx, id, payload)
+	}
+}
 
-		var kvErr *gocbcore.KeyValueError
-		if err != nil && errors.As(err, &kvErr) && kvErr.StatusCode == memd.StatusKeyNotFound {
-			err = CreateDocument(ctx, s.client.GetMetaAgent(), s.scopeName, s.collectionName, id, []byte{}, helpers.JSONFlags, 0)
+func (s *cbMetadata) upsertXattrs(ctx context.Context, id []byte, payload []byte) error {
+	err := UpsertXattrs(ctx, s.client.GetMetaAgent(), s.scopeName, s.collectionName, id, helpers.Name, payload, 0)
 
-			if err == nil {
-				err = UpsertXattrs(ctx, s.client.GetMetaAgent(), s.scopeName, s.collectionName, id, helpers.Name, payload, 0)
-			}
+	var kvErr *gocbcore.KeyValueError
+	if err != nil && errors.As(err, &kvErr) && kvErr.StatusCode == memd.StatusKeyNotFound {
+		err = CreateDocument(ctx, s.client.GetMetaAgent(), s.scopeName, s.collectionName, id, []byte{}, helpers.JSONFlags, 0)
+
+		if err == nil {
+			err = UpsertXattrs(ctx, s.client.GetMetaAgent(), s.scopeName, s.collectionName, id, helpers.Name, payload, 0)
 		}
-		return err
 	}
+	return err
 }
 
 func (s *cbMetadata) Load(
@@ -68,64 +74,142 @@ func (s *cbMetadata) Load(
 ) (*wrapper.ConcurrentSwissMap[uint16, *models.CheckpointDocument], bool, error) {
 	state := wrapper.CreateConcurrentSwissMap[uint16, *models.CheckpointDocument](1024)
 
-	wg := &sync.WaitGroup{}
-	wg.Add(len(vbIds))
+	eg, ctx := errgroup.WithContext(context.Background())
+	eg.SetLimit(s.config.Checkpoint.LoadConcurrency)
 
-	exist := false
+	var exist atomic.Bool
 
 	for _, vbID := range vbIds {
-		go func(vbID uint16) {
-			var err error
+		vbID := vbID
+
+		eg.Go(func() error {
+			doc, found, err := s.loadVBucketCheckpoint(ctx, vbID, bucketUUID)
+			if err != nil {
+				logger.Log.Error("cannot load checkpoint, vbID: %d, err: %v", vbID, err)
+				return err
+			}
+
+			if found {
+				exist.Store(true)
+			}
 
-			id := getCheckpointID(vbID, s.config.Dcp.Group.Name)
+			state.Store(vbID, doc)
 
-			data, err := GetXattrs(context.Background(), s.client.GetMetaAgent(), s.scopeName, s.collectionName, id, helpers.Name)
+			return nil
+		})
+	}
 
-			var doc *models.CheckpointDocument
+	if err := eg.Wait(); err != nil {
+		return nil, false, err
+	}
 
-			if err == nil {
-				err = jsoniter.Unmarshal(data, &doc)
+	return state, exist.Load(), nil
+}
 
-				if err != nil {
-					doc = models.NewEmptyCheckpointDocument(bucketUUID)
-				} else {
-					exist = true
-				}
-			} else {
-				doc = models.NewEmptyCheckpointDocument(bucketUUID)
+func (s *cbMetadata) loadVBucketCheckpoint(
+	ctx context.Context,
+	vbID uint16,
+	bucketUUID string,
+) (*models.CheckpointDocument, bool, error) {
+	id := getCheckpointID(vbID, s.config.Dcp.Group.Name)
+
+	data, err := s.getCheckpoint(ctx, id)
+	if err != nil {
+		var kvErr *gocbcore.KeyValueError
+		if errors.As(err, &kvErr) && kvErr.StatusCode == memd.StatusKeyNotFound {
+			return models.NewEmptyCheckpointDocument(bucketUUID), false, nil
+		}
+
+		return nil, false, err
+	}
+
+	var doc *models.CheckpointDocument
+
+	err = jsoniter.Unmarshal(data, &doc)
+	if err != nil {
+		logger.Log.Warn("cannot unmarshal checkpoint, it is reset, vbID: %d, err: %v", vbID, err)
+		return models.NewEmptyCheckpointDocument(bucketUUID), false, nil
+	}
+
+	return doc, true, nil
+}
+
+func (s *cbMetadata) getXattrs(ctx context.Context, id []byte) ([]byte, error) {
+	return GetXattrs(ctx, s.client.GetMetaAgent(), s.scopeName, s.collectionName, id, helpers.Name)
+}
+
+// migrate copies the checkpoints from the old location unless they already exist in the current one
+func (s *cbMetadata) migrate(from *cbMetadata, vbIds []uint16) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.Checkpoint.Timeout)
+	defer cancel()
+
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(s.config.Checkpoint.LoadConcurrency)
+
+	var migrated atomic.Int32
+
+	for _, vbID := range vbIds {
+		id := getCheckpointID(vbID, s.config.Dcp.Group.Name)
+
+		eg.Go(func() error {
+			_, err := s.getCheckpoint(ctx, id)
+			if !isKeyNotFoundError(err) {
+				return err
 			}
 
-			var kvErr *gocbcore.KeyValueError
-			if err == nil || errors.As(err, &kvErr) && kvErr.StatusCode == memd.StatusKeyNotFound {
-				state.Store(vbID, doc)
-			} else {
-				logger.Log.Error("cannot load checkpoint, vbID: %d, err: %v", vbID, err)
-				panic(err)
+			data, err := from.getCheckpoint(ctx, id)
+			if isKeyNotFoundError(err) {
+				return nil
 			}
 
-			wg.Done()
-		}(vbID)
+			if err != nil {
+				return err
+			}
+
+			migrated.Add(1)
+
+			return s.saveCheckpoint(ctx, id, data)
+		})
 	}
 
-	wg.Wait()
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+
+	logger.Log.Info(
+		"migrated %d checkpoints from %s.%s to %s.%s",
+		migrated.Load(), from.scopeName, from.collectionName, s.scopeName, s.collectionName,
+	)
+
+	return nil
+}
 
-	return state, exist, nil
+func isKeyNotFoundError(err error) bool {
+	var kvErr *gocbcore.KeyValueError
+	return err != nil && errors.As(err, &kvErr) && kvErr.StatusCode == memd.StatusKeyNotFound
 }
 
 func (s *cbMetadata) Clear(vbIds []uint16) error {
 	ctx, cancel := context.WithTimeout(context.Background(), s.config.Checkpoint.Timeout)
 	defer cancel()
 
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(s.config.Checkpoint.LoadConcurrency)
+
 	for _, vbID := range vbIds {
 		id := getCheckpointID(vbID, s.config.Dcp.Group.Name)
 
-		err := DeleteDocument(ctx, s.client.GetMetaAgent(), s.scopeName, s.collectionName, id)
-		if err != nil {
-			return err
-		}
+		eg.Go(func() error {
+			err := DeleteDocument(ctx, s.client.GetMetaAgent(), s.scopeName, s.collectionName, id)
+			if err != nil && !isKeyNotFoundError(err) {
+				return err
+			}
+
+			return nil
+		})
 	}
 
-	return nil
+	return eg.Wait()
 }
 
 func NewCBMetadata(client Client, config *config.Dcp) metadata.Metadata {
@@ -137,12 +221,138 @@ func NewCBMetadata(client Client, config *config.Dcp) metadata.Metadata {
 
 	_, scope, collection, _, _ := config.GetCouchbaseMetadata()
 
-	return &cbMetadata{
+	cbm := &cbMetadata{
 		client:         client,
 		config:         config,
 		scopeName:      scope,
 		collectionName: collection,
 	}
+	cbm.getCheckpoint = cbm.getXattrs
+	cbm.saveCheckpoint = cbm.upsertXattrs
+
+	return cbm
+}
+
+// MigrateMetadata copies the checkpoints and the membership documents from metadata.migrateFrom once,
+// the completion is recorded in the current location so the next starts skip it
+func MigrateMetadata(client Client, config *config.Dcp) error {
+	scope, collection, ok := config.GetMetadataMigrateFrom()
+	if !ok || !config.IsCouchbaseMetadata() || config.Metadata.ReadOnly {
+		return nil
+	}
+
+	_, currentScope, currentCollection, _, _ := config.GetCouchbaseMetadata()
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.Checkpoint.Timeout)
+	defer cancel()
+
+	agent := client.GetMetaAgent()
+	migrationID := getMigrationID(config.Dcp.Group.Name)
+	source := []byte(scope + "." + collection)
+
+	completed, err := Get(ctx, agent, currentScope, currentCollection, migrationID)
+	if err == nil && string(completed) == string(source) {
+		logger.Log.Debug("metadata is already migrated from %s", source)
+		return nil
+	}
+
+	if err != nil && !isKeyNotFoundError(err) {
+		return err
+	}
+
+	cbm := NewCBMetadata(client, config).(*cbMetadata)
+
+	from := &cbMetadata{
+		client:         client,
+		config:         config,
+		scopeName:      scope,
+		collectionName: collection,
+	}
+	from.getCheckpoint = from.getXattrs
+
+	vbIds := make([]uint16, client.GetNumVBuckets())
+	for i := range vbIds {
+		vbIds[i] = uint16(i)
+	}
+
+	if err = cbm.migrate(from, vbIds); err != nil {
+		return err
+	}
+
+	if err = cbm.migrateMembership(from); err != nil {
+		return err
+	}
+
+	return CreateDocument(ctx, agent, currentScope, currentCollection, migrationID, source, helpers.BinaryFlags, 0)
+}
+
+// migrateMembership copies the membership index and its live instances unless the index exists in the current location
+func (s *cbMetadata) migrateMembership(from *cbMetadata) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.Checkpoint.Timeout)
+	defer cancel()
+
+	agent := s.client.GetMetaAgent()
+	instanceAll := []byte(helpers.Prefix + s.config.Dcp.Group.Name + ":" + _type + ":all")
+
+	data, err := Get(ctx, agent, from.scopeName, from.collectionName, instanceAll)
+	if isKeyNotFoundError(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	all, err := decodeIndex(data)
+	if err != nil {
+		return err
+	}
+
+	migrated := 0
+
+	for id := range all {
+		instance, err := Get(ctx, agent, from.scopeName, from.collectionName, []byte(id))
+		if isKeyNotFoundError(err) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		err = InsertDocument(ctx, agent, s.scopeName, s.collectionName, []byte(id), instance, helpers.JSONFlags, _expirySec)
+		if err != nil && !errors.Is(err, gocbcore.ErrDocumentExists) {
+			return err
+		}
+
+		migrated++
+	}
+
+	flags := helpers.JSONFlags
+	if isCompressedIndex(data) {
+		flags = helpers.BinaryFlags
+	}
+
+	err = InsertDocument(ctx, agent, s.scopeName, s.collectionName, instanceAll, data, flags, 0)
+	if errors.Is(err, gocbcore.ErrDocumentExists) {
+		logger.Log.Info("membership index exists in %s.%s, it is not migrated", s.scopeName, s.collectionName)
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	logger.Log.Info(
+		"migrated membership index with %d instances from %s.%s to %s.%s",
+		migrated, from.scopeName, from.collectionName, s.scopeName, s.collectionName,
+	)
+
+	return nil
+}
+
+func getMigrationID(groupName string) []byte {
+	return []byte(helpers.Prefix + groupName + ":migration")
 }
 
 func getCheckpointID(vbID uint16, groupName string) []byte {
@@ -0,0 +1,245 @@
+package couchbase
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Trendyol/go-dcp/config"
+	"github.com/Trendyol/go-dcp/models"
+
+	"github.com/couchbase/gocbcore/v10"
+	"github.com/couchbase/gocbcore/v10/memd"
+	jsoniter "github.com/json-iterator/go"
+)
+
+func newTestCBMetadata(loadConcurrency int, inFlight *int32, maxInFlight *int32) *cbMetadata {
+	c := &config.Dcp{Checkpoint: config.Checkpoint{LoadConcurrency: loadConcurrency}}
+	c.Dcp.Group.Name = "test"
+
+	return &cbMetadata{
+		config: c,
+		getCheckpoint: func(_ context.Context, id []byte) ([]byte, error) {
+			current := atomic.AddInt32(inFlight, 1)
+			defer atomic.AddInt32(inFlight, -1)
+
+			for {
+				previous := atomic.LoadInt32(maxInFlight)
+				if current <= previous || atomic.CompareAndSwapInt32(maxInFlight, previous, current) {
+					break
+				}
+			}
+
+			time.Sleep(5 * time.Millisecond)
+
+			vbID, _ := strconv.Atoi(string(id[bytes.LastIndexByte(id, ':')+1:]))
+			if vbID%2 == 1 {
+				return nil, &gocbcore.KeyValueError{StatusCode: memd.StatusKeyNotFound}
+			}
+
+			return jsoniter.Marshal(&models.CheckpointDocument{
+				Checkpoint: &models.CheckpointDocumentCheckpoint{SeqNo: uint64(vbID)},
+			})
+		},
+	}
+}
+
+func loadTestCheckpoints(t *testing.T, loadConcurrency int) (time.Duration, int32) {
+	var inFlight, maxInFlight int32
+
+	metadata := newTestCBMetadata(loadConcurrency, &inFlight, &maxInFlight)
+
+	vbIds := make([]uint16, 0, 32)
+	for i := 0; i < 32; i++ {
+		vbIds = append(vbIds, uint16(i))
+	}
+
+	start := time.Now()
+
+	state, exist, err := metadata.Load(vbIds, "uuid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	elapsed := time.Since(start)
+
+	if !exist {
+		t.Errorf("checkpoint must exist")
+	}
+
+	for _, vbID := range vbIds {
+		doc, ok := state.Load(vbID)
+		if !ok {
+			t.Fatalf("checkpoint of vbID %v is not loaded", vbID)
+		}
+
+		if vbID%2 == 0 && doc.Checkpoint.SeqNo != uint64(vbID) {
+			t.Errorf("checkpoint seqNo of vbID %v = %v", vbID, doc.Checkpoint.SeqNo)
+		}
+
+		if vbID%2 == 1 && (doc.BucketUUID != "uuid" || doc.Checkpoint.SeqNo != 0) {
+			t.Errorf("checkpoint of vbID %v must be empty", vbID)
+		}
+	}
+
+	return elapsed, maxInFlight
+}
+
+func TestCBMetadata_LoadIsBoundedAndFasterInParallel(t *testing.T) {
+	serial, serialMaxInFlight := loadTestCheckpoints(t, 1)
+	parallel, parallelMaxInFlight := loadTestCheckpoints(t, 8)
+
+	if serialMaxInFlight != 1 {
+		t.Errorf("serial load in flight = %v, want 1", serialMaxInFlight)
+	}
+
+	if parallelMaxInFlight > 8 {
+		t.Errorf("parallel load in flight = %v, want at most 8", parallelMaxInFlight)
+	}
+
+	if parallel >= serial/2 {
+		t.Errorf("parallel load took %v, serial load took %v", parallel, serial)
+	}
+}
+
+type fakeCheckpointStore struct {
+	docs map[string][]byte
+	lock sync.Mutex
+}
+
+func (s *fakeCheckpointStore) get(_ context.Context, id []byte) ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	doc, ok := s.docs[string(id)]
+	if !ok {
+		return nil, &gocbcore.KeyValueError{StatusCode: memd.StatusKeyNotFound}
+	}
+
+	return doc, nil
+}
+
+func (s *fakeCheckpointStore) save(_ context.Context, id []byte, payload []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.docs[string(id)] = payload
+
+	return nil
+}
+
+func TestCBMetadata_MigrateCopiesCheckpointsAndResumesFromThem(t *testing.T) {
+	c := &config.Dcp{}
+	c.Dcp.Group.Name = "test"
+	c.ApplyDefaults()
+
+	checkpoint := func(seqNo uint64) []byte {
+		payload, _ := jsoniter.Marshal(&models.CheckpointDocument{
+			Checkpoint: &models.CheckpointDocumentCheckpoint{SeqNo: seqNo, Snapshot: &models.CheckpointDocumentSnapshot{}},
+		})
+		return payload
+	}
+
+	oldStore := &fakeCheckpointStore{docs: map[string][]byte{
+		string(getCheckpointID(0, "test")): checkpoint(10),
+		string(getCheckpointID(1, "test")): checkpoint(20),
+	}}
+	newStore := &fakeCheckpointStore{docs: map[string][]byte{
+		string(getCheckpointID(1, "test")): checkpoint(25),
+	}}
+
+	from := &cbMetadata{config: c, getCheckpoint: oldStore.get, scopeName: "old"}
+	to := &cbMetadata{config: c, getCheckpoint: newStore.get, saveCheckpoint: newStore.save, scopeName: "new"}
+
+	if err := to.migrate(from, []uint16{0, 1, 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(newStore.docs) != 2 {
+		t.Errorf("new location has %v checkpoints, want 2", len(newStore.docs))
+	}
+
+	state, exist, err := to.Load([]uint16{0, 1, 2}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !exist {
+		t.Fatal("migrated checkpoints must exist")
+	}
+
+	for vbID, expected := range map[uint16]uint64{0: 10, 1: 25, 2: 0} {
+		if doc, _ := state.Load(vbID); doc.Checkpoint.SeqNo != expected {
+			t.Errorf("vbID %v seqNo = %v, want %v", vbID, doc.Checkpoint.SeqNo, expected)
+		}
+	}
+}
+
+func TestCBMetadata_LoadResetsCorruptCheckpoints(t *testing.T) {
+	c := &config.Dcp{}
+	c.Dcp.Group.Name = "test"
+	c.ApplyDefaults()
+
+	store := &fakeCheckpointStore{docs: map[string][]byte{
+		string(getCheckpointID(0, "test")): []byte("{corrupt"),
+	}}
+
+	m := &cbMetadata{config: c, getCheckpoint: store.get}
+
+	state, exist, err := m.Load([]uint16{0}, "uuid")
+	if err != nil {
+		t.Fatalf("corrupt checkpoint must not fail the load, err: %v", err)
+	}
+
+	if exist {
+		t.Error("corrupt checkpoint must not exist")
+	}
+
+	if doc, _ := state.Load(0); doc == nil || doc.Checkpoint.SeqNo != 0 || doc.BucketUUID != "uuid" {
+		t.Errorf("doc = %+v, want an empty checkpoint", doc)
+	}
+}
+
+func TestCBMetadata_SaveUsesCheckpointSaveTimeout(t *testing.T) {
+	c := &config.Dcp{Timeouts: config.Timeouts{CheckpointSave: 2 * time.Second}}
+	c.Dcp.Group.Name = "test"
+	c.ApplyDefaults()
+
+	var timeout time.Duration
+
+	m := &cbMetadata{
+		config: c,
+		saveCheckpoint: func(ctx context.Context, _ []byte, _ []byte) error {
+			deadline, _ := ctx.Deadline()
+			timeout = time.Until(deadline)
+			return nil
+		},
+	}
+
+	err := m.Save(map[uint16]*models.CheckpointDocument{0: models.NewEmptyCheckpointDocument("uuid")}, map[uint16]bool{0: true}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if timeout > 2*time.Second || timeout < 1500*time.Millisecond {
+		t.Errorf("timeout = %v, want checkpoint save timeout 2s", timeout)
+	}
+}
+
+func TestMigrateMetadata_ReadOnlyDoesNotWrite(t *testing.T) {
+	c := &config.Dcp{}
+	c.Dcp.Group.Name = "test"
+	c.Metadata.Type = config.MetadataTypeCouchbase
+	c.Metadata.ReadOnly = true
+	c.Metadata.MigrateFrom.Scope = "old"
+	c.ApplyDefaults()
+
+	// a nil client panics on any read or write
+	if err := MigrateMetadata(nil, c); err != nil {
+		t.Fatal(err)
+	}
+}
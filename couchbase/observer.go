@@ -1,8 +1,12 @@
 package couchbase
 
 import (
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/json-iterator/go"
+
 	"github.com/Trendyol/go-dcp/wrapper"
 
 	"github.com/Trendyol/go-dcp/logger"
@@ -30,12 +34,17 @@ type Observer interface {
 	OSOSnapshot(snapshot models.DcpOSOSnapshot)
 	SeqNoAdvanced(advanced gocbcore.DcpSeqNoAdvanced)
 	GetMetrics() *wrapper.ConcurrentSwissMap[uint16, *ObserverMetric]
+	GetQueueDepth() int
+	GetBackpressureCount() int64
+	GetSeqNoGapCount() int64
 	Listen() models.ListenerCh
 	Close()
 	CloseEnd()
 	ListenEnd() models.ListenerEndCh
 	AddCatchup(vbID uint16, seqNo gocbcore.SeqNo)
 	SetVbUUID(vbID uint16, vbUUID gocbcore.VbUUID)
+	SetSourceNode(vbID uint16, node string)
+	Rollback(vbID uint16, seqNo gocbcore.SeqNo)
 }
 
 const DefaultCollectionName = "_default"
@@ -68,8 +77,12 @@ type observer struct {
 	listenerCh             models.ListenerCh
 	persistSeqNo           *wrapper.ConcurrentSwissMap[uint16, gocbcore.SeqNo]
 	uuIDMap                *wrapper.ConcurrentSwissMap[uint16, gocbcore.VbUUID]
+	sourceNodes            *wrapper.ConcurrentSwissMap[uint16, string]
+	lastSeqNos             *wrapper.ConcurrentSwissMap[uint16, uint64]
 	config                 *dcp.Dcp
 	catchupNeededVbIDCount int
+	backpressure           int64
+	seqNoGaps              int64
 	closed                 bool
 }
 
@@ -151,7 +164,37 @@ func (so *observer) sendOrSkip(args models.ListenerArgs) {
 		}
 	}()
 
-	so.listenerCh <- args
+	select {
+	case so.listenerCh <- args:
+	default:
+		// buffer is full, the agent waits until the listener catches up
+		atomic.AddInt64(&so.backpressure, 1)
+		so.listenerCh <- args
+	}
+}
+
+// checkSeqNoGap warns when consecutive seqNos of a snapshot are further apart than dcp.listener.seqNoGapThreshold,
+// it is skipped when the streams are filtered by collections since the writes to other collections are gaps
+func (so *observer) checkSeqNoGap(vbID uint16, seqNo uint64) {
+	threshold := so.config.Dcp.Listener.SeqNoGapThreshold
+	if threshold == 0 || so.config.IsCollectionModeEnabled() {
+		return
+	}
+
+	lastSeqNo, ok := so.lastSeqNos.Load(vbID)
+	so.lastSeqNos.Store(vbID, seqNo)
+
+	if !ok || seqNo <= lastSeqNo+threshold {
+		return
+	}
+
+	atomic.AddInt64(&so.seqNoGaps, 1)
+
+	snapshot, _ := so.currentSnapshots.Load(vbID)
+	logger.Log.Warn(
+		"seqNo gap of %d is detected in the snapshot %d-%d, vbID: %d, previous seqNo: %d, seqNo: %d",
+		seqNo-lastSeqNo, snapshot.StartSeqNo, snapshot.EndSeqNo, vbID, lastSeqNo, seqNo,
+	)
 }
 
 func (so *observer) SnapshotMarker(event models.DcpSnapshotMarker) {
@@ -160,31 +203,85 @@ func (so *observer) SnapshotMarker(event models.DcpSnapshotMarker) {
 		EndSeqNo:   event.EndSeqNo,
 	})
 
+	// seqNos jump between snapshots
+	so.lastSeqNos.Delete(event.VbID)
+
 	so.sendOrSkip(models.ListenerArgs{
 		Event: event,
 	})
 }
 
-func (so *observer) Mutation(mutation gocbcore.DcpMutation) { //nolint:dupl
+// isSoftDeleted returns true if the value matches the configured tombstone field and value
+func (so *observer) isSoftDeleted(value []byte) bool {
+	softDelete := so.config.Dcp.Listener.SoftDelete
+	if softDelete.Field == "" {
+		return false
+	}
+
+	var path []interface{}
+	for _, key := range strings.Split(softDelete.Field, ".") {
+		path = append(path, key)
+	}
+
+	field := jsoniter.Get(value, path...)
+
+	return field.LastError() == nil && field.ToString() == softDelete.Value
+}
+
+func toDeletion(mutation *gocbcore.DcpMutation) *gocbcore.DcpDeletion {
+	return &gocbcore.DcpDeletion{
+		SeqNo:        mutation.SeqNo,
+		RevNo:        mutation.RevNo,
+		Cas:          mutation.Cas,
+		CollectionID: mutation.CollectionID,
+		VbID:         mutation.VbID,
+		StreamID:     mutation.StreamID,
+		Datatype:     mutation.Datatype,
+		Key:          mutation.Key,
+		Value:        mutation.Value,
+	}
+}
+
+func (so *observer) Mutation(mutation gocbcore.DcpMutation) {
 	if !so.canForward(mutation.VbID, mutation.SeqNo) {
 		return
 	}
 
 	if currentSnapshot, ok := so.currentSnapshots.Load(mutation.VbID); ok && currentSnapshot != nil {
+		so.checkSeqNoGap(mutation.VbID, mutation.SeqNo)
+
 		vbUUID, _ := so.uuIDMap.Load(mutation.VbID)
 
-		so.sendOrSkip(models.ListenerArgs{
-			Event: models.InternalDcpMutation{
-				DcpMutation: &mutation,
-				Offset: &models.Offset{
-					SnapshotMarker: currentSnapshot,
-					VbUUID:         vbUUID,
-					SeqNo:          mutation.SeqNo,
+		offset := &models.Offset{
+			SnapshotMarker: currentSnapshot,
+			VbUUID:         vbUUID,
+			SeqNo:          mutation.SeqNo,
+		}
+		collectionName := so.convertToCollectionName(mutation.CollectionID)
+		eventTime := time.Unix(int64(mutation.Cas/1000000000), 0)
+		sourceNode, _ := so.sourceNodes.Load(mutation.VbID)
+
+		if so.isSoftDeleted(mutation.Value) {
+			so.sendOrSkip(models.ListenerArgs{
+				Event: models.InternalDcpDeletion{
+					DcpDeletion:    toDeletion(&mutation),
+					Offset:         offset,
+					CollectionName: collectionName,
+					EventTime:      eventTime,
+					SourceNode:     sourceNode,
 				},
-				CollectionName: so.convertToCollectionName(mutation.CollectionID),
-				EventTime:      time.Unix(int64(mutation.Cas/1000000000), 0),
-			},
-		})
+			})
+		} else {
+			so.sendOrSkip(models.ListenerArgs{
+				Event: models.InternalDcpMutation{
+					DcpMutation:    &mutation,
+					Offset:         offset,
+					CollectionName: collectionName,
+					EventTime:      eventTime,
+					SourceNode:     sourceNode,
+				},
+			})
+		}
 	}
 
 	if metric, ok := so.metrics.Load(mutation.VbID); ok {
@@ -202,7 +299,10 @@ func (so *observer) Deletion(deletion gocbcore.DcpDeletion) { //nolint:dupl
 	}
 
 	if currentSnapshot, ok := so.currentSnapshots.Load(deletion.VbID); ok && currentSnapshot != nil {
+		so.checkSeqNoGap(deletion.VbID, deletion.SeqNo)
+
 		vbUUID, _ := so.uuIDMap.Load(deletion.VbID)
+		sourceNode, _ := so.sourceNodes.Load(deletion.VbID)
 
 		so.sendOrSkip(models.ListenerArgs{
 			Event: models.InternalDcpDeletion{
@@ -214,6 +314,7 @@ func (so *observer) Deletion(deletion gocbcore.DcpDeletion) { //nolint:dupl
 				},
 				CollectionName: so.convertToCollectionName(deletion.CollectionID),
 				EventTime:      time.Unix(int64(deletion.Cas/1000000000), 0),
+				SourceNode:     sourceNode,
 			},
 		})
 	}
@@ -233,7 +334,10 @@ func (so *observer) Expiration(expiration gocbcore.DcpExpiration) { //nolint:dup
 	}
 
 	if currentSnapshot, ok := so.currentSnapshots.Load(expiration.VbID); ok && currentSnapshot != nil {
+		so.checkSeqNoGap(expiration.VbID, expiration.SeqNo)
+
 		vbUUID, _ := so.uuIDMap.Load(expiration.VbID)
+		sourceNode, _ := so.sourceNodes.Load(expiration.VbID)
 
 		so.sendOrSkip(models.ListenerArgs{
 			Event: models.InternalDcpExpiration{
@@ -245,6 +349,7 @@ func (so *observer) Expiration(expiration gocbcore.DcpExpiration) { //nolint:dup
 				},
 				CollectionName: so.convertToCollectionName(expiration.CollectionID),
 				EventTime:      time.Unix(int64(expiration.Cas/1000000000), 0),
+				SourceNode:     sourceNode,
 			},
 		})
 	}
@@ -346,6 +451,7 @@ func (so *observer) SeqNoAdvanced(advanced gocbcore.DcpSeqNoAdvanced) {
 	}
 
 	so.currentSnapshots.Store(advanced.VbID, snapshot)
+	so.lastSeqNos.Delete(advanced.VbID)
 
 	vbUUID, _ := so.uuIDMap.Load(advanced.VbID)
 
@@ -365,6 +471,21 @@ func (so *observer) GetMetrics() *wrapper.ConcurrentSwissMap[uint16, *ObserverMe
 	return so.metrics
 }
 
+// GetQueueDepth returns the number of received dcp messages waiting for the listener
+func (so *observer) GetQueueDepth() int {
+	return len(so.listenerCh)
+}
+
+// GetBackpressureCount returns the number of dcp messages that waited for a full listener buffer
+func (so *observer) GetBackpressureCount() int64 {
+	return atomic.LoadInt64(&so.backpressure)
+}
+
+// GetSeqNoGapCount returns the number of seqNo gaps above dcp.listener.seqNoGapThreshold within snapshots
+func (so *observer) GetSeqNoGapCount() int64 {
+	return atomic.LoadInt64(&so.seqNoGaps)
+}
+
 func (so *observer) Listen() models.ListenerCh {
 	return so.listenerCh
 }
@@ -394,6 +515,18 @@ func (so *observer) SetVbUUID(vbID uint16, vbUUID gocbcore.VbUUID) {
 	so.uuIDMap.Store(vbID, vbUUID)
 }
 
+func (so *observer) SetSourceNode(vbID uint16, node string) {
+	so.sourceNodes.Store(vbID, node)
+}
+
+// Rollback blocks until the listeners quiesce the events of the vBucket after the rollback seqNo
+func (so *observer) Rollback(vbID uint16, seqNo gocbcore.SeqNo) {
+	so.bus.Emit(helpers.RollbackBusEventName, models.Rollback{
+		VbID:  vbID,
+		SeqNo: seqNo,
+	})
+}
+
 // nolint:staticcheck
 func (so *observer) CloseEnd() {
 	defer func() {
@@ -413,6 +546,8 @@ func NewObserver(
 	observer := &observer{
 		currentSnapshots: wrapper.CreateConcurrentSwissMap[uint16, *models.SnapshotMarker](1024),
 		uuIDMap:          wrapper.CreateConcurrentSwissMap[uint16, gocbcore.VbUUID](100),
+		sourceNodes:      wrapper.CreateConcurrentSwissMap[uint16, string](100),
+		lastSeqNos:       wrapper.CreateConcurrentSwissMap[uint16, uint64](1024),
 		metrics:          wrapper.CreateConcurrentSwissMap[uint16, *ObserverMetric](100),
 		catchup:          wrapper.CreateConcurrentSwissMap[uint16, uint64](100),
 		collectionIDs:    collectionIDs,
@@ -0,0 +1,205 @@
+package couchbase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Trendyol/go-dcp/config"
+	"github.com/Trendyol/go-dcp/helpers"
+	"github.com/Trendyol/go-dcp/logger"
+	"github.com/Trendyol/go-dcp/models"
+
+	"github.com/couchbase/gocbcore/v10"
+)
+
+func TestObserver_SoftDeletedMutationIsDeliveredAsDeletion(t *testing.T) {
+	c := &config.Dcp{
+		RollbackMitigation: config.RollbackMitigation{Disabled: true},
+		Logging:            config.Logging{Level: logger.ERROR},
+	}
+	c.Dcp.Listener.SoftDelete = config.DCPSoftDelete{Field: "meta.deleted", Value: "true"}
+	c.ApplyDefaults()
+
+	observer := NewObserver(c, map[uint32]string{0: DefaultCollectionName}, helpers.NewBus())
+
+	observer.SnapshotMarker(models.DcpSnapshotMarker{VbID: 0, StartSeqNo: 1, EndSeqNo: 2})
+	observer.Mutation(gocbcore.DcpMutation{VbID: 0, SeqNo: 1, Key: []byte("alive"), Value: []byte(`{"meta":{"deleted":false}}`)})
+	observer.Mutation(gocbcore.DcpMutation{VbID: 0, SeqNo: 2, Key: []byte("deleted"), Value: []byte(`{"meta":{"deleted":true}}`)})
+
+	if _, ok := (<-observer.Listen()).Event.(models.DcpSnapshotMarker); !ok {
+		t.Fatal("first event must be the snapshot marker")
+	}
+
+	if mutation, ok := (<-observer.Listen()).Event.(models.InternalDcpMutation); !ok || string(mutation.Key) != "alive" {
+		t.Fatalf("event = %v, want a mutation", mutation)
+	}
+
+	deletion, ok := (<-observer.Listen()).Event.(models.InternalDcpDeletion)
+	if !ok {
+		t.Fatal("soft deleted document is not delivered as a deletion")
+	}
+
+	if string(deletion.Key) != "deleted" || deletion.SeqNo != 2 || deletion.Offset.SeqNo != 2 {
+		t.Errorf("deletion = %v", deletion)
+	}
+}
+
+func TestObserver_EventsCarryVbUUIDOfOpenedFailoverEntry(t *testing.T) {
+	c := &config.Dcp{
+		RollbackMitigation: config.RollbackMitigation{Disabled: true},
+		Logging:            config.Logging{Level: logger.ERROR},
+	}
+	c.ApplyDefaults()
+
+	observer := NewObserver(c, nil, helpers.NewBus())
+
+	receive := func() models.InternalDcpMutation {
+		t.Helper()
+
+		for args := range observer.Listen() {
+			if mutation, ok := args.Event.(models.InternalDcpMutation); ok {
+				return mutation
+			}
+		}
+
+		t.Fatal("listener channel is closed")
+		return models.InternalDcpMutation{}
+	}
+
+	// stream is opened against the latest failover log entry
+	observer.SetVbUUID(0, 111)
+	observer.SnapshotMarker(models.DcpSnapshotMarker{VbID: 0, StartSeqNo: 1, EndSeqNo: 2})
+	observer.Mutation(gocbcore.DcpMutation{VbID: 0, SeqNo: 2, Key: []byte("key")})
+
+	if mutation := receive(); mutation.Offset.VbUUID != 111 || mutation.Offset.SeqNo != 2 {
+		t.Fatalf("offset = %v, want vbUUID 111 and seqNo 2", mutation.Offset)
+	}
+
+	// rollback reopens the stream against a new failover log entry
+	observer.Rollback(0, 1)
+	observer.SetVbUUID(0, 222)
+	observer.SnapshotMarker(models.DcpSnapshotMarker{VbID: 0, StartSeqNo: 1, EndSeqNo: 2})
+	observer.Mutation(gocbcore.DcpMutation{VbID: 0, SeqNo: 2, Key: []byte("key")})
+
+	if mutation := receive(); mutation.Offset.VbUUID != 222 || mutation.Offset.SeqNo != 2 {
+		t.Fatalf("offset = %v, want vbUUID 222 and seqNo 2", mutation.Offset)
+	}
+}
+
+func TestObserver_EventsCarrySourceNode(t *testing.T) {
+	c := &config.Dcp{
+		RollbackMitigation: config.RollbackMitigation{Disabled: true},
+		Logging:            config.Logging{Level: logger.ERROR},
+	}
+	c.ApplyDefaults()
+
+	observer := NewObserver(c, nil, helpers.NewBus())
+
+	observer.SetSourceNode(0, "10.0.0.1:11210")
+
+	for vbID, want := range map[uint16]string{0: "10.0.0.1:11210", 1: ""} {
+		observer.SnapshotMarker(models.DcpSnapshotMarker{VbID: vbID, StartSeqNo: 1, EndSeqNo: 1})
+		observer.Mutation(gocbcore.DcpMutation{VbID: vbID, SeqNo: 1, Key: []byte("key")})
+
+		for args := range observer.Listen() {
+			if mutation, ok := args.Event.(models.InternalDcpMutation); ok {
+				if mutation.SourceNode != want {
+					t.Errorf("vbID %v source node = %q, want %q", vbID, mutation.SourceNode, want)
+				}
+				break
+			}
+		}
+	}
+}
+
+func TestObserver_ListenerBufferAbsorbsBurstWithoutBackpressure(t *testing.T) {
+	backpressure := func(bufferSize uint) int64 {
+		c := &config.Dcp{
+			RollbackMitigation: config.RollbackMitigation{Disabled: true},
+			Logging:            config.Logging{Level: logger.ERROR},
+		}
+		c.Dcp.Listener.BufferSize = bufferSize
+		c.ApplyDefaults()
+
+		observer := NewObserver(c, nil, helpers.NewBus())
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 100; i++ {
+				<-observer.Listen()
+				// a short pause of the processing like GC
+				time.Sleep(100 * time.Microsecond)
+			}
+		}()
+
+		for seqNo := uint64(1); seqNo <= 50; seqNo++ {
+			observer.SnapshotMarker(models.DcpSnapshotMarker{VbID: 0, StartSeqNo: seqNo, EndSeqNo: seqNo})
+			observer.Mutation(gocbcore.DcpMutation{VbID: 0, SeqNo: seqNo, Key: []byte("key")})
+		}
+		<-done
+
+		return observer.GetBackpressureCount()
+	}
+
+	if unbuffered := backpressure(1); unbuffered == 0 {
+		t.Errorf("burst must backpressure with a single slot buffer")
+	}
+
+	if buffered := backpressure(128); buffered != 0 {
+		t.Errorf("backpressure = %v, want the burst absorbed by the buffer", buffered)
+	}
+}
+
+func TestObserver_SeqNoGapWithinSnapshotIsWarned(t *testing.T) {
+	c := &config.Dcp{
+		RollbackMitigation: config.RollbackMitigation{Disabled: true},
+		Logging:            config.Logging{Level: logger.ERROR},
+	}
+	c.Dcp.Listener.SeqNoGapThreshold = 10
+	c.ApplyDefaults()
+
+	recorder := &warnRecorder{Logger: logger.Log}
+	previous := logger.Log
+	logger.Log = recorder
+	defer func() { logger.Log = previous }()
+
+	observer := NewObserver(c, nil, helpers.NewBus())
+
+	observer.SnapshotMarker(models.DcpSnapshotMarker{VbID: 0, StartSeqNo: 1, EndSeqNo: 100})
+	for _, seqNo := range []uint64{1, 2, 12} {
+		observer.Mutation(gocbcore.DcpMutation{VbID: 0, SeqNo: seqNo, Key: []byte("key")})
+	}
+
+	if gaps := observer.GetSeqNoGapCount(); gaps != 0 || len(recorder.warnings) != 0 {
+		t.Fatalf("gaps = %v, warnings = %v, want none within the threshold", gaps, recorder.warnings)
+	}
+
+	observer.Deletion(gocbcore.DcpDeletion{VbID: 0, SeqNo: 50, Key: []byte("key")})
+
+	if gaps := observer.GetSeqNoGapCount(); gaps != 1 || len(recorder.warnings) != 1 {
+		t.Fatalf("gaps = %v, warnings = %v, want the gap from 12 to 50 warned", gaps, recorder.warnings)
+	}
+
+	// the next snapshot starts far after the previous one
+	observer.SnapshotMarker(models.DcpSnapshotMarker{VbID: 0, StartSeqNo: 500, EndSeqNo: 600})
+	observer.Mutation(gocbcore.DcpMutation{VbID: 0, SeqNo: 500, Key: []byte("key")})
+	observer.Mutation(gocbcore.DcpMutation{VbID: 0, SeqNo: 505, Key: []byte("key")})
+
+	if gaps := observer.GetSeqNoGapCount(); gaps != 1 {
+		t.Errorf("gaps = %v, snapshot boundary jumps must not be warned", gaps)
+	}
+	// writes to the other collections are gaps of a filtered stream
+	filtered := *c
+	filtered.CollectionNames = []string{"orders"}
+
+	observer = NewObserver(&filtered, nil, helpers.NewBus())
+
+	observer.SnapshotMarker(models.DcpSnapshotMarker{VbID: 0, StartSeqNo: 1, EndSeqNo: 100})
+	observer.Mutation(gocbcore.DcpMutation{VbID: 0, SeqNo: 1, Key: []byte("key")})
+	observer.Mutation(gocbcore.DcpMutation{VbID: 0, SeqNo: 50, Key: []byte("key")})
+
+	if gaps := observer.GetSeqNoGapCount(); gaps != 0 {
+		t.Errorf("gaps = %v, want none with a collection filter", gaps)
+	}
+}
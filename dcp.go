@@ -1,11 +1,13 @@
 package dcp
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"reflect"
+	"sync"
 	"syscall"
 	"time"
 
@@ -33,6 +35,8 @@ import (
 	"github.com/Trendyol/go-dcp/servicediscovery"
 )
 
+const _lastErrorsSize = 10
+
 type Dcp interface {
 	WaitUntilReady() chan struct{}
 	Start()
@@ -42,9 +46,16 @@ type Dcp interface {
 	SetMetadata(metadata metadata.Metadata)
 	SetMetricCollectors(collectors ...prometheus.Collector)
 	SetEventHandler(handler models.EventHandler)
+	SetPartitionFunc(partitionFunc models.PartitionFunc)
+	LastErrors() map[string][]helpers.ErrorRecord
+	OnLeaderAcquired(task func(ctx context.Context))
+	OnLeaderLost(task func())
+	OnCaughtUp(task func(event models.ConsumerCaughtUp))
+	OnVBucketIdle(task func(vbID uint16, seqNo uint64))
 }
 
 type dcp struct {
+	ctx               context.Context
 	client            couchbase.Client
 	stream            stream.Stream
 	api               api.API
@@ -53,15 +64,26 @@ type dcp struct {
 	serviceDiscovery  servicediscovery.ServiceDiscovery
 	metadata          metadata.Metadata
 	eventHandler      models.EventHandler
+	partitionFunc     models.PartitionFunc
+	lastErrors        *helpers.LastErrors
+	goroutines        *helpers.Goroutines
+	leaderCancel      context.CancelFunc
 	apiShutdown       chan struct{}
 	stopCh            chan struct{}
 	healCheckFailedCh chan struct{}
+	apiFailedCh       chan struct{}
 	config            *config.Dcp
 	healthCheckTicker *time.Ticker
+	cancel            context.CancelFunc
 	listener          models.Listener
 	readyCh           chan struct{}
 	cancelCh          chan os.Signal
 	metricCollectors  []prometheus.Collector
+	leaderAcquired    []func(ctx context.Context)
+	leaderLost        []func()
+	caughtUp          []func(event models.ConsumerCaughtUp)
+	vBucketIdle       []func(vbID uint16, seqNo uint64)
+	leaderLock        sync.Mutex
 }
 
 func (s *dcp) startHealthCheck() {
@@ -71,6 +93,7 @@ func (s *dcp) startHealthCheck() {
 		for range s.healthCheckTicker.C {
 			if err := s.client.Ping(); err != nil {
 				logger.Log.Error("health check failed: %v", err)
+				s.lastErrors.Add(models.ClientSubsystem, err)
 				s.healthCheckTicker.Stop()
 				s.healCheckFailedCh <- struct{}{}
 				break
@@ -95,6 +118,84 @@ func (s *dcp) SetEventHandler(eventHandler models.EventHandler) {
 	s.eventHandler = eventHandler
 }
 
+func (s *dcp) SetPartitionFunc(partitionFunc models.PartitionFunc) {
+	s.partitionFunc = partitionFunc
+}
+
+// OnLeaderAcquired runs the task when this instance becomes the leader, its context is cancelled on leadership loss
+func (s *dcp) OnLeaderAcquired(task func(ctx context.Context)) {
+	s.leaderAcquired = append(s.leaderAcquired, task)
+}
+
+func (s *dcp) OnLeaderLost(task func()) {
+	s.leaderLost = append(s.leaderLost, task)
+}
+
+// OnCaughtUp runs the task once when all assigned vBuckets reach the high seqNos observed at startup
+func (s *dcp) OnCaughtUp(task func(event models.ConsumerCaughtUp)) {
+	s.caughtUp = append(s.caughtUp, task)
+}
+
+func (s *dcp) caughtUpListener(event interface{}) {
+	for _, task := range s.caughtUp {
+		go task(event.(models.ConsumerCaughtUp))
+	}
+}
+
+// OnVBucketIdle runs the task for every assigned vBucket without events for dcp.listener.idleInterval, repeatedly while it is idle
+func (s *dcp) OnVBucketIdle(task func(vbID uint16, seqNo uint64)) {
+	s.vBucketIdle = append(s.vBucketIdle, task)
+}
+
+func (s *dcp) vBucketIdleListener(event interface{}) {
+	idle := event.(models.VBucketIdle)
+
+	for _, task := range s.vBucketIdle {
+		go task(idle.VbID, idle.SeqNo)
+	}
+}
+
+func (s *dcp) leaderAcquiredListener(_ interface{}) {
+	s.leaderLock.Lock()
+	defer s.leaderLock.Unlock()
+
+	if s.leaderCancel != nil {
+		return
+	}
+
+	var ctx context.Context
+	ctx, s.leaderCancel = context.WithCancel(s.ctx)
+
+	for _, task := range s.leaderAcquired {
+		go task(ctx)
+	}
+}
+
+func (s *dcp) leaderLostListener(_ interface{}) {
+	s.leaderLock.Lock()
+	defer s.leaderLock.Unlock()
+
+	if s.leaderCancel == nil {
+		return
+	}
+
+	s.leaderCancel()
+	s.leaderCancel = nil
+
+	for _, task := range s.leaderLost {
+		task()
+	}
+}
+
+func (s *dcp) LastErrors() map[string][]helpers.ErrorRecord {
+	return s.lastErrors.Get()
+}
+
+func (s *dcp) errorOccurredListener(event interface{}) {
+	subsystemError := event.(models.SubsystemError)
+	s.lastErrors.Add(subsystemError.Subsystem, subsystemError.Err)
+}
+
 func (s *dcp) membershipChangedListener(_ interface{}) {
 	s.stream.Rebalance()
 }
@@ -104,6 +205,12 @@ func (s *dcp) Start() {
 	if s.metadata == nil {
 		switch {
 		case s.config.IsCouchbaseMetadata():
+			// a metadata set by SetMetadata is not migrated
+			if err := couchbase.MigrateMetadata(s.client, s.config); err != nil {
+				logger.Log.Error("error while migrating metadata: %v", err)
+				panic(err)
+			}
+
 			s.metadata = couchbase.NewCBMetadata(s.client, s.config)
 		case s.config.IsFileMetadata():
 			s.metadata = metadata.NewFSMetadata(s.config)
@@ -119,14 +226,18 @@ func (s *dcp) Start() {
 	logger.Log.Info("using %v metadata", reflect.TypeOf(s.metadata))
 
 	bus := helpers.NewBus()
+	bus.Subscribe(helpers.ErrorOccurredBusEventName, s.errorOccurredListener)
+	bus.Subscribe(helpers.ConsumerCaughtUpBusEventName, s.caughtUpListener)
+	bus.Subscribe(helpers.VBucketIdleBusEventName, s.vBucketIdleListener)
 
 	vBuckets := s.client.GetNumVBuckets()
 
-	s.vBucketDiscovery = stream.NewVBucketDiscovery(s.client, s.config, vBuckets, bus)
+	s.vBucketDiscovery = stream.NewVBucketDiscovery(s.client, s.config, vBuckets, bus, s.goroutines)
 
 	s.stream = stream.NewStream(
-		s.client, s.metadata, s.config, s.vBucketDiscovery,
-		s.listener, s.client.GetCollectionIDs(s.config.ScopeName, s.config.CollectionNames), s.stopCh, bus, s.eventHandler,
+		s.ctx, s.client, s.metadata, s.config, s.vBucketDiscovery,
+		s.listener, s.client.GetCollectionIDs(s.config.ScopeName, s.config.CollectionNames), s.stopCh, bus, s.eventHandler, s.partitionFunc,
+		s.goroutines,
 	)
 
 	if s.config.LeaderElection.Enabled {
@@ -134,6 +245,9 @@ func (s *dcp) Start() {
 		s.serviceDiscovery.StartHeartbeat()
 		s.serviceDiscovery.StartMonitor()
 
+		bus.Subscribe(helpers.LeaderAcquiredBusEventName, s.leaderAcquiredListener)
+		bus.Subscribe(helpers.LeaderLostBusEventName, s.leaderLostListener)
+
 		s.leaderElection = stream.NewLeaderElection(s.config, s.serviceDiscovery, bus)
 		s.leaderElection.Start()
 	}
@@ -149,8 +263,13 @@ func (s *dcp) Start() {
 				s.api.Shutdown()
 			}()
 
-			s.api = api.NewAPI(s.config, s.client, s.stream, s.serviceDiscovery, s.vBucketDiscovery, s.metricCollectors...)
-			s.api.Listen()
+			s.api = api.NewAPI(
+				s.config, s.client, s.stream, s.serviceDiscovery, s.vBucketDiscovery, s.lastErrors, s.goroutines, s.metricCollectors...,
+			)
+			if err := s.api.Listen(); err != nil {
+				s.lastErrors.Add(models.APISubsystem, err)
+				s.apiFailedCh <- struct{}{}
+			}
 		}()
 	}
 
@@ -164,10 +283,16 @@ func (s *dcp) Start() {
 
 	s.readyCh <- struct{}{}
 
+	s.waitUntilStopped()
+}
+
+func (s *dcp) waitUntilStopped() {
 	select {
 	case <-s.stopCh:
 	case <-s.cancelCh:
 	case <-s.healCheckFailedCh:
+	case <-s.apiFailedCh:
+	case <-s.ctx.Done():
 	}
 }
 
@@ -181,6 +306,10 @@ func (s *dcp) Close() {
 	}
 	s.vBucketDiscovery.Close()
 
+	// in-flight events are drained after the streams stop receiving new ones
+	s.stream.Stop()
+	s.stream.Drain()
+
 	if s.config.Checkpoint.Type == stream.CheckpointTypeAuto {
 		s.stream.Save()
 	}
@@ -200,6 +329,8 @@ func (s *dcp) Close() {
 	s.client.DcpClose()
 	s.client.Close()
 
+	s.cancel()
+
 	logger.Log.Info("dcp stream closed")
 }
 
@@ -211,8 +342,13 @@ func (s *dcp) GetConfig() *config.Dcp {
 	return s.config
 }
 
-func newDcp(config *config.Dcp, listener models.Listener) (Dcp, error) {
+func newDcp(ctx context.Context, config *config.Dcp, listeners []models.Listener) (Dcp, error) {
 	config.ApplyDefaults()
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
 	copyOfConfig := config
 	printConfiguration(*copyOfConfig)
 
@@ -229,7 +365,16 @@ func newDcp(config *config.Dcp, listener models.Listener) (Dcp, error) {
 		return nil, err
 	}
 
+	ctx, cancel := context.WithCancel(ctx)
+
+	listener := listeners[0]
+	if len(listeners) > 1 {
+		listener = stream.NewFanOutListener(config.Dcp.Listener.FanOutAckPolicy, listeners...)
+	}
+
 	return &dcp{
+		ctx:               ctx,
+		cancel:            cancel,
 		client:            client,
 		listener:          listener,
 		config:            config,
@@ -237,9 +382,13 @@ func newDcp(config *config.Dcp, listener models.Listener) (Dcp, error) {
 		cancelCh:          make(chan os.Signal, 1),
 		stopCh:            make(chan struct{}, 1),
 		healCheckFailedCh: make(chan struct{}, 1),
+		apiFailedCh:       make(chan struct{}, 1),
 		readyCh:           make(chan struct{}, 1),
 		metricCollectors:  []prometheus.Collector{},
 		eventHandler:      models.DefaultEventHandler,
+		partitionFunc:     models.DefaultPartitionFunc,
+		lastErrors:        helpers.NewLastErrors(_lastErrorsSize),
+		goroutines:        helpers.NewGoroutines(),
 	}, nil
 }
 
@@ -248,24 +397,46 @@ func newDcp(config *config.Dcp, listener models.Listener) (Dcp, error) {
 // config: path to a configuration file or a configuration struct
 // listener is a callback function that will be called when a mutation, deletion or expiration event occurs
 func NewDcp(cfg any, listener models.Listener) (Dcp, error) {
+	return NewDcpWithContext(context.Background(), cfg, listener)
+}
+
+// NewDcpWithContext creates a new Dcp client bound to the given context
+//
+// ctx: the listener contexts are derived from it, Start returns when it is done
+func NewDcpWithContext(ctx context.Context, cfg any, listener models.Listener) (Dcp, error) {
+	return newDcpWithListeners(ctx, cfg, []models.Listener{listener})
+}
+
+// NewDcpWithListeners creates a new Dcp client that delivers every event to all listeners
+//
+// listeners are invoked concurrently, the checkpoint advances according to dcp.listener.fanOutAckPolicy
+func NewDcpWithListeners(cfg any, listeners ...models.Listener) (Dcp, error) {
+	if len(listeners) == 0 {
+		return nil, errors.New("no listener")
+	}
+
+	return newDcpWithListeners(context.Background(), cfg, listeners)
+}
+
+func newDcpWithListeners(ctx context.Context, cfg any, listeners []models.Listener) (Dcp, error) {
 	switch v := cfg.(type) {
 	case *config.Dcp:
-		return newDcp(v, listener)
+		return newDcp(ctx, v, listeners)
 	case config.Dcp:
-		return newDcp(&v, listener)
+		return newDcp(ctx, &v, listeners)
 	case string:
-		return newDcpWithPath(v, listener)
+		return newDcpWithPath(ctx, v, listeners)
 	default:
 		return nil, errors.New("invalid config")
 	}
 }
 
-func newDcpWithPath(path string, listener models.Listener) (Dcp, error) {
+func newDcpWithPath(ctx context.Context, path string, listeners []models.Listener) (Dcp, error) {
 	c, err := newDcpConfig(path)
 	if err != nil {
 		return nil, err
 	}
-	return newDcp(&c, listener)
+	return newDcp(ctx, &c, listeners)
 }
 
 func newDcpConfig(path string) (config.Dcp, error) {
@@ -2,11 +2,14 @@ package dcp
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/Trendyol/go-dcp/helpers"
 	"github.com/Trendyol/go-dcp/logger"
 
 	"github.com/Trendyol/go-dcp/config"
@@ -35,6 +38,114 @@ var c = &config.Dcp{
 	Debug: true,
 }
 
+func newLeaderTaskInstance(bus helpers.Bus, started chan context.Context, lost chan struct{}) *dcp {
+	s := &dcp{ctx: context.Background()}
+
+	s.OnLeaderAcquired(func(ctx context.Context) {
+		started <- ctx
+	})
+	s.OnLeaderLost(func() {
+		lost <- struct{}{}
+	})
+
+	bus.Subscribe(helpers.LeaderAcquiredBusEventName, s.leaderAcquiredListener)
+	bus.Subscribe(helpers.LeaderLostBusEventName, s.leaderLostListener)
+
+	return s
+}
+
+func TestDcp_LeaderTasksFollowLeadershipTransfer(t *testing.T) {
+	firstBus, secondBus := helpers.NewBus(), helpers.NewBus()
+	firstStarted, secondStarted := make(chan context.Context, 1), make(chan context.Context, 1)
+	firstLost, secondLost := make(chan struct{}, 1), make(chan struct{}, 1)
+
+	newLeaderTaskInstance(firstBus, firstStarted, firstLost)
+	newLeaderTaskInstance(secondBus, secondStarted, secondLost)
+
+	firstBus.Emit(helpers.LeaderAcquiredBusEventName, nil)
+	secondBus.Emit(helpers.LeaderLostBusEventName, nil)
+
+	var firstCtx context.Context
+
+	select {
+	case firstCtx = <-firstStarted:
+	case <-time.After(time.Second):
+		t.Fatal("task is not started on the leader")
+	}
+
+	select {
+	case <-secondStarted:
+		t.Fatal("task must not be started on the follower")
+	case <-secondLost:
+		t.Fatal("follower never had the leadership")
+	default:
+	}
+
+	firstBus.Emit(helpers.LeaderLostBusEventName, nil)
+	secondBus.Emit(helpers.LeaderAcquiredBusEventName, nil)
+
+	select {
+	case <-firstCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("task context is not cancelled on the previous leader")
+	}
+
+	select {
+	case <-firstLost:
+	default:
+		t.Fatal("leader lost task is not called on the previous leader")
+	}
+
+	select {
+	case secondCtx := <-secondStarted:
+		if secondCtx.Err() != nil {
+			t.Fatal("task context of the new leader must not be cancelled")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("task is not started on the new leader")
+	}
+}
+
+func TestNewDcp_RejectsInvalidGroupNameBeforeConnecting(t *testing.T) {
+	for _, name := range []string{"", "group:name"} {
+		_, err := NewDcp(config.Dcp{
+			Hosts:      []string{"unreachable.invalid:8091"},
+			BucketName: "dcp-test",
+			Dcp:        config.ExternalDcp{Group: config.DCPGroup{Name: name}},
+		}, func(ctx *models.ListenerContext) {})
+
+		if !errors.Is(err, config.ErrInvalidGroupName) {
+			t.Errorf("group name %q: err = %v, want %v", name, err, config.ErrInvalidGroupName)
+		}
+	}
+}
+
+func TestDcp_StopsWhenContextIsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := &dcp{
+		ctx:               ctx,
+		stopCh:            make(chan struct{}, 1),
+		cancelCh:          make(chan os.Signal, 1),
+		healCheckFailedCh: make(chan struct{}, 1),
+	}
+
+	stopped := make(chan struct{})
+
+	go func() {
+		s.waitUntilStopped()
+		close(stopped)
+	}()
+
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("dcp is not stopped after context is cancelled")
+	}
+}
+
 func setupContainer(ctx context.Context) (testcontainers.Container, error) {
 	req := testcontainers.ContainerRequest{
 		Image:        "couchbase:6.5.1",
@@ -7,6 +7,14 @@ const (
 
 	MembershipChangedBusEventName   string = "membershipChanged"
 	PersistSeqNoChangedBusEventName string = "persistSeqNoChanged"
+	ErrorOccurredBusEventName       string = "errorOccurred"
+	LeaderAcquiredBusEventName      string = "leaderAcquired"
+	LeaderLostBusEventName          string = "leaderLost"
+	RollbackBusEventName            string = "rollback"
+	ConsumerCaughtUpBusEventName    string = "consumerCaughtUp"
+	VBucketIdleBusEventName         string = "vBucketIdle"
+	LeaderStepDownBusEventName      string = "leaderStepDown"
 
-	JSONFlags uint32 = 50333696
+	JSONFlags   uint32 = 50333696
+	BinaryFlags uint32 = 50331648
 )
@@ -0,0 +1,82 @@
+package helpers
+
+import (
+	"sync"
+	"time"
+)
+
+type ErrorRecord struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+type ErrorRing struct {
+	records []ErrorRecord
+	next    int
+	full    bool
+}
+
+func (r *ErrorRing) Add(record ErrorRecord) {
+	r.records[r.next] = record
+	r.next = (r.next + 1) % len(r.records)
+
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Records returns the stored records from the oldest to the newest
+func (r *ErrorRing) Records() []ErrorRecord {
+	if !r.full {
+		return append([]ErrorRecord{}, r.records[:r.next]...)
+	}
+
+	return append(append([]ErrorRecord{}, r.records[r.next:]...), r.records[:r.next]...)
+}
+
+func NewErrorRing(size int) *ErrorRing {
+	return &ErrorRing{
+		records: make([]ErrorRecord, size),
+	}
+}
+
+type LastErrors struct {
+	rings map[string]*ErrorRing
+	size  int
+	lock  sync.RWMutex
+}
+
+func (e *LastErrors) Add(subsystem string, err error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	ring, ok := e.rings[subsystem]
+	if !ok {
+		ring = NewErrorRing(e.size)
+		e.rings[subsystem] = ring
+	}
+
+	ring.Add(ErrorRecord{
+		Time:    time.Now(),
+		Message: err.Error(),
+	})
+}
+
+func (e *LastErrors) Get() map[string][]ErrorRecord {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	result := make(map[string][]ErrorRecord, len(e.rings))
+	for subsystem, ring := range e.rings {
+		result[subsystem] = ring.Records()
+	}
+
+	return result
+}
+
+func NewLastErrors(size int) *LastErrors {
+	return &LastErrors{
+		rings: map[string]*ErrorRing{},
+		size:  size,
+	}
+}
@@ -0,0 +1,48 @@
+package helpers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrorRing_KeepsLastRecordsInOrder(t *testing.T) {
+	ring := NewErrorRing(3)
+
+	for _, message := range []string{"1", "2", "3", "4", "5"} {
+		ring.Add(ErrorRecord{Message: message})
+	}
+
+	records := ring.Records()
+
+	if len(records) != 3 {
+		t.Fatalf("ring has %v records, want 3", len(records))
+	}
+
+	for i, expected := range []string{"3", "4", "5"} {
+		if records[i].Message != expected {
+			t.Errorf("records[%v] = %v, want %v", i, records[i].Message, expected)
+		}
+	}
+}
+
+func TestLastErrors_GroupsBySubsystem(t *testing.T) {
+	lastErrors := NewLastErrors(2)
+
+	lastErrors.Add("membership", errors.New("index not found"))
+	lastErrors.Add("checkpoint", errors.New("timeout"))
+	lastErrors.Add("checkpoint", errors.New("temporary failure"))
+
+	result := lastErrors.Get()
+
+	if len(result["membership"]) != 1 || result["membership"][0].Message != "index not found" {
+		t.Errorf("membership errors = %v", result["membership"])
+	}
+
+	if len(result["checkpoint"]) != 2 || result["checkpoint"][1].Message != "temporary failure" {
+		t.Errorf("checkpoint errors = %v", result["checkpoint"])
+	}
+
+	if result["checkpoint"][0].Time.IsZero() {
+		t.Errorf("error time is not set")
+	}
+}
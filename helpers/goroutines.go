@@ -0,0 +1,98 @@
+package helpers
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	GoroutineRunning = "running"
+	GoroutineStopped = "stopped"
+)
+
+type GoroutineState struct {
+	LastActivity time.Time `json:"lastActivity"`
+	Name         string    `json:"name"`
+	State        string    `json:"state"`
+}
+
+// Goroutine is a handle of a tracked goroutine, nil handles are no-ops
+type Goroutine struct {
+	lastActivity atomic.Int64
+	stopped      atomic.Bool
+}
+
+// Touch records an activity like a processed tick or task
+func (g *Goroutine) Touch() {
+	if g == nil {
+		return
+	}
+
+	g.lastActivity.Store(time.Now().UnixNano())
+}
+
+func (g *Goroutine) Stop() {
+	if g == nil {
+		return
+	}
+
+	g.Touch()
+	g.stopped.Store(true)
+}
+
+// Goroutines is a registry of the long living goroutines of go-dcp, nil registries do not track
+type Goroutines struct {
+	goroutines map[string]*Goroutine
+	lock       sync.RWMutex
+}
+
+// Start registers a goroutine as running, a goroutine started with the same name replaces the previous one
+func (r *Goroutines) Start(name string) *Goroutine {
+	if r == nil {
+		return nil
+	}
+
+	g := &Goroutine{}
+	g.Touch()
+
+	r.lock.Lock()
+	r.goroutines[name] = g
+	r.lock.Unlock()
+
+	return g
+}
+
+// Get returns the states sorted by name
+func (r *Goroutines) Get() []GoroutineState {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	states := make([]GoroutineState, 0, len(r.goroutines))
+
+	for name, g := range r.goroutines {
+		state := GoroutineRunning
+		if g.stopped.Load() {
+			state = GoroutineStopped
+		}
+
+		states = append(states, GoroutineState{
+			Name:         name,
+			State:        state,
+			LastActivity: time.Unix(0, g.lastActivity.Load()),
+		})
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Name < states[j].Name
+	})
+
+	return states
+}
+
+func NewGoroutines() *Goroutines {
+	return &Goroutines{
+		goroutines: map[string]*Goroutine{},
+	}
+}
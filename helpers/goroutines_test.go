@@ -0,0 +1,27 @@
+package helpers
+
+import "testing"
+
+func TestGoroutines_RestartReplacesStoppedGoroutine(t *testing.T) {
+	goroutines := NewGoroutines()
+
+	goroutines.Start("stream-listen").Stop()
+	goroutines.Start("stream-listen")
+
+	states := goroutines.Get()
+	if len(states) != 1 || states[0].State != GoroutineRunning {
+		t.Errorf("goroutines = %v, want a single running stream-listen", states)
+	}
+}
+
+func TestGoroutines_NilRegistryDoesNotTrack(t *testing.T) {
+	var goroutines *Goroutines
+
+	tracked := goroutines.Start("worker-0")
+	tracked.Touch()
+	tracked.Stop()
+
+	if tracked != nil {
+		t.Errorf("tracked = %v, want nil", tracked)
+	}
+}
@@ -0,0 +1,53 @@
+package helpers
+
+import (
+	"sort"
+	"sync"
+)
+
+type Histogram struct {
+	upperBounds []float64
+	counts      []uint64
+	sum         float64
+	count       uint64
+	lock        sync.Mutex
+}
+
+func (h *Histogram) Observe(value float64) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	i := sort.SearchFloat64s(h.upperBounds, value)
+	if i < len(h.upperBounds) {
+		h.counts[i]++
+	}
+
+	h.count++
+	h.sum += value
+}
+
+// Snapshot returns cumulative counts per upper bound as prometheus const histograms expect
+func (h *Histogram) Snapshot() (uint64, float64, map[float64]uint64) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	buckets := make(map[float64]uint64, len(h.upperBounds))
+
+	var cumulative uint64
+	for i, upperBound := range h.upperBounds {
+		cumulative += h.counts[i]
+		buckets[upperBound] = cumulative
+	}
+
+	return h.count, h.sum, buckets
+}
+
+func NewHistogram(upperBounds []float64) *Histogram {
+	sorted := append([]float64{}, upperBounds...)
+	sort.Float64s(sorted)
+
+	return &Histogram{
+		upperBounds: sorted,
+		counts:      make([]uint64, len(sorted)),
+	}
+}
@@ -0,0 +1,28 @@
+package helpers
+
+import "testing"
+
+func TestHistogram_Observe(t *testing.T) {
+	histogram := NewHistogram([]float64{100, 1, 10})
+
+	for _, value := range []float64{0, 1, 5, 50, 5000} {
+		histogram.Observe(value)
+	}
+
+	count, sum, buckets := histogram.Snapshot()
+
+	if count != 5 {
+		t.Errorf("count = %v, want 5", count)
+	}
+
+	if sum != 5056 {
+		t.Errorf("sum = %v, want 5056", sum)
+	}
+
+	expected := map[float64]uint64{1: 2, 10: 3, 100: 4}
+	for upperBound, want := range expected {
+		if buckets[upperBound] != want {
+			t.Errorf("bucket %v = %v, want %v", upperBound, buckets[upperBound], want)
+		}
+	}
+}
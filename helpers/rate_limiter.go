@@ -0,0 +1,77 @@
+package helpers
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket allowing limit events per second, 0 means unlimited
+type RateLimiter struct {
+	last   time.Time
+	tokens float64
+	limit  int
+	lock   sync.Mutex
+}
+
+func (r *RateLimiter) refill(now time.Time) {
+	r.tokens = math.Min(float64(r.limit), r.tokens+now.Sub(r.last).Seconds()*float64(r.limit))
+	r.last = now
+}
+
+func (r *RateLimiter) SetLimit(limit int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.refill(time.Now())
+	r.limit = limit
+	r.tokens = math.Min(float64(limit), r.tokens)
+}
+
+func (r *RateLimiter) Limit() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.limit
+}
+
+// Wait blocks until an event is allowed, the limit can be changed while waiting
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		r.lock.Lock()
+
+		if r.limit == 0 {
+			r.lock.Unlock()
+			return nil
+		}
+
+		r.refill(time.Now())
+
+		if r.tokens >= 1 {
+			r.tokens--
+			r.lock.Unlock()
+			return nil
+		}
+
+		wait := time.Duration((1 - r.tokens) / float64(r.limit) * float64(time.Second))
+
+		r.lock.Unlock()
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func NewRateLimiter(limit int) *RateLimiter {
+	return &RateLimiter{
+		limit: limit,
+		last:  time.Now(),
+	}
+}
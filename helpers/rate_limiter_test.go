@@ -0,0 +1,47 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_WaitFollowsLimit(t *testing.T) {
+	r := NewRateLimiter(100)
+
+	start := time.Now()
+
+	for i := 0; i < 10; i++ {
+		if err := r.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("10 events at 100/s took %v, want at least 80ms", elapsed)
+	}
+
+	r.SetLimit(0)
+
+	start = time.Now()
+
+	for i := 0; i < 10000; i++ {
+		_ = r.Wait(context.Background())
+	}
+
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("unlimited events took %v", elapsed)
+	}
+}
+
+func TestRateLimiter_WaitReturnsWhenContextIsDone(t *testing.T) {
+	r := NewRateLimiter(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := r.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
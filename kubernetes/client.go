@@ -16,8 +16,8 @@ import (
 
 type Client interface {
 	CoordinationV1() v1.CoordinationV1Interface
-	AddLabel(namespace string, key string, value string)
-	RemoveLabel(namespace string, key string)
+	AddLabel(namespace string, key string, value string) error
+	RemoveLabel(namespace string, key string) error
 }
 
 type client struct {
@@ -25,7 +25,7 @@ type client struct {
 	*clientSet.Clientset
 }
 
-func (le *client) AddLabel(namespace string, key string, value string) {
+func (le *client) AddLabel(namespace string, key string, value string) error {
 	_, err := le.CoreV1().Pods(namespace).Patch(
 		context.Background(),
 		le.myIdentity.Name,
@@ -35,9 +35,11 @@ func (le *client) AddLabel(namespace string, key string, value string) {
 	if err != nil {
 		logger.Log.Error("failed to add label: %v", err)
 	}
+
+	return err
 }
 
-func (le *client) RemoveLabel(namespace string, key string) {
+func (le *client) RemoveLabel(namespace string, key string) error {
 	_, err := le.CoreV1().Pods(namespace).Patch(
 		context.Background(),
 		le.myIdentity.Name,
@@ -47,6 +49,8 @@ func (le *client) RemoveLabel(namespace string, key string) {
 	if err != nil {
 		logger.Log.Error("failed to remove label: %v", err)
 	}
+
+	return err
 }
 
 func NewClient(myIdentity *models.Identity) Client {
@@ -1,6 +1,8 @@
 package kubernetes
 
 import (
+	"context"
+
 	"github.com/Trendyol/go-dcp/config"
 	"github.com/Trendyol/go-dcp/helpers"
 	"github.com/Trendyol/go-dcp/membership"
@@ -19,6 +21,19 @@ func (h *haMembership) GetInfo() *membership.Model {
 	return <-h.infoChan
 }
 
+func (h *haMembership) GetInfoContext(ctx context.Context) (*membership.Model, error) {
+	if h.info != nil {
+		return h.info, nil
+	}
+
+	select {
+	case model := <-h.infoChan:
+		return model, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
 func (h *haMembership) Close() {
 }
 
@@ -2,7 +2,10 @@ package kubernetes
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Trendyol/go-dcp/config"
@@ -20,12 +23,45 @@ import (
 	"k8s.io/client-go/tools/leaderelection/resourcelock"
 )
 
+const (
+	_leaseDuration = 8 * time.Second
+	_renewDeadline = 5 * time.Second
+	_retryPeriod   = 1 * time.Second
+)
+
+var errSteppedDown = errors.New("leader stepped down, lease is not acquired before the lease duration")
+
+// stepDownLock lets a stepped down elector follow the next leader without acquiring the lease until the back off ends
+type stepDownLock struct {
+	resourcelock.Interface
+	backOffUntil atomic.Int64
+}
+
+func (l *stepDownLock) Create(ctx context.Context, ler resourcelock.LeaderElectionRecord) error {
+	if time.Now().UnixNano() < l.backOffUntil.Load() {
+		return errSteppedDown
+	}
+
+	return l.Interface.Create(ctx, ler)
+}
+
+func (l *stepDownLock) Update(ctx context.Context, ler resourcelock.LeaderElectionRecord) error {
+	if time.Now().UnixNano() < l.backOffUntil.Load() {
+		return errSteppedDown
+	}
+
+	return l.Interface.Update(ctx, ler)
+}
+
 type leaderElector struct {
 	client             Client
 	myIdentity         *models.Identity
 	handler            leaderelector.Handler
+	cancel             context.CancelFunc
 	leaseLockName      string
 	leaseLockNamespace string
+	cancelLock         sync.Mutex
+	steppedDown        atomic.Bool
 }
 
 func (le *leaderElector) Run(ctx context.Context) {
@@ -33,18 +69,23 @@ func (le *leaderElector) Run(ctx context.Context) {
 		OnStartedLeading: func(c context.Context) {
 			logger.Log.Info("granted to leader")
 
-			le.client.AddLabel(le.leaseLockNamespace, "role", "leader")
-
 			le.handler.OnBecomeLeader()
+
+			le.addLeaderLabel(c)
 		},
 		OnStoppedLeading: func() {
 			logger.Log.Info("revoked from leader")
 
-			le.client.RemoveLabel(le.leaseLockNamespace, "role")
+			_ = le.client.RemoveLabel(le.leaseLockNamespace, "role")
 
 			le.handler.OnResignLeader()
 		},
 		OnNewLeader: func(leaderIdentityStr string) {
+			// the lease is released
+			if leaderIdentityStr == "" {
+				return
+			}
+
 			leaderIdentity := models.NewIdentityFromStr(leaderIdentityStr)
 
 			if le.myIdentity.Equal(leaderIdentity) {
@@ -53,41 +94,100 @@ func (le *leaderElector) Run(ctx context.Context) {
 
 			logger.Log.Info("granted to follower for leader: %s", leaderIdentity.Name)
 
-			le.client.AddLabel(le.leaseLockNamespace, "role", "follower")
+			_ = le.client.AddLabel(le.leaseLockNamespace, "role", "follower")
 
 			le.handler.OnBecomeFollower(leaderIdentity)
 		},
 	}
 
-	go func() {
-		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
-			Lock: &resourcelock.LeaseLock{
-				LeaseMeta: v1.ObjectMeta{
-					Name:      le.leaseLockName,
-					Namespace: le.leaseLockNamespace,
-				},
-				Client: le.client.CoordinationV1(),
-				LockConfig: resourcelock.ResourceLockConfig{
-					Identity: le.myIdentity.String(),
-				},
+	lock := &stepDownLock{
+		Interface: &resourcelock.LeaseLock{
+			LeaseMeta: v1.ObjectMeta{
+				Name:      le.leaseLockName,
+				Namespace: le.leaseLockNamespace,
+			},
+			Client: le.client.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{
+				Identity: le.myIdentity.String(),
 			},
-			ReleaseOnCancel: true,
-			LeaseDuration:   8 * time.Second,
-			RenewDeadline:   5 * time.Second,
-			RetryPeriod:     1 * time.Second,
-			Callbacks:       callback,
-		})
+		},
+	}
+
+	electionConfig := leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		ReleaseOnCancel: true,
+		LeaseDuration:   _leaseDuration,
+		RenewDeadline:   _renewDeadline,
+		RetryPeriod:     _retryPeriod,
+		Callbacks:       callback,
+	}
+
+	go func() {
+		for {
+			runCtx, cancel := context.WithCancel(ctx)
+
+			le.cancelLock.Lock()
+			le.cancel = cancel
+			le.cancelLock.Unlock()
+
+			leaderelection.RunOrDie(runCtx, electionConfig)
+			cancel()
+
+			if !le.steppedDown.Swap(false) || ctx.Err() != nil {
+				return
+			}
+
+			// the elector runs again at once to follow the next leader,
+			// the released lease is left to the other candidates for the lease duration
+			lock.backOffUntil.Store(time.Now().Add(_leaseDuration).UnixNano())
+		}
 	}()
 }
 
+// StepDown releases the lease, the elector follows the next leader and can acquire the lease again after the lease duration
+func (le *leaderElector) StepDown() {
+	le.cancelLock.Lock()
+	defer le.cancelLock.Unlock()
+
+	if le.cancel == nil {
+		return
+	}
+
+	le.steppedDown.Store(true)
+	le.cancel()
+}
+
+func (le *leaderElector) addLeaderLabel(ctx context.Context) {
+	ticker := time.NewTicker(_retryPeriod)
+	defer ticker.Stop()
+
+	for {
+		err := le.client.AddLabel(le.leaseLockNamespace, "role", "leader")
+
+		le.handler.OnMetadataWrite(err)
+
+		if err == nil {
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 func (le *leaderElector) membershipChangedListener(event interface{}) {
 	model := event.(*membership.Model)
 
-	le.client.AddLabel(
+	err := le.client.AddLabel(
 		le.leaseLockNamespace,
 		"member",
 		fmt.Sprintf("%v_%v", model.MemberNumber, model.TotalMembers),
 	)
+
+	le.handler.OnMetadataWrite(err)
 }
 
 func NewLeaderElector(
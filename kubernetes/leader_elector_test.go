@@ -0,0 +1,193 @@
+package kubernetes
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Trendyol/go-dcp/config"
+	"github.com/Trendyol/go-dcp/helpers"
+	"github.com/Trendyol/go-dcp/logger"
+	"github.com/Trendyol/go-dcp/models"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	v1 "k8s.io/client-go/kubernetes/typed/coordination/v1"
+)
+
+// fakeLeases keeps the leases in memory with optimistic concurrency like the api server
+type fakeLeases struct {
+	v1.CoordinationV1Interface
+	v1.LeaseInterface
+	leases  map[string]*coordinationv1.Lease
+	version int
+	lock    sync.Mutex
+}
+
+func newFakeLeases() *fakeLeases {
+	return &fakeLeases{leases: map[string]*coordinationv1.Lease{}}
+}
+
+func (l *fakeLeases) Leases(_ string) v1.LeaseInterface {
+	return l
+}
+
+func (l *fakeLeases) Get(_ context.Context, name string, _ metav1.GetOptions) (*coordinationv1.Lease, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	lease, ok := l.leases[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}, name)
+	}
+
+	return lease.DeepCopy(), nil
+}
+
+func (l *fakeLeases) Create(_ context.Context, lease *coordinationv1.Lease, _ metav1.CreateOptions) (*coordinationv1.Lease, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if _, ok := l.leases[lease.Name]; ok {
+		return nil, apierrors.NewAlreadyExists(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}, lease.Name)
+	}
+
+	return l.store(lease), nil
+}
+
+func (l *fakeLeases) Update(_ context.Context, lease *coordinationv1.Lease, _ metav1.UpdateOptions) (*coordinationv1.Lease, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if stored, ok := l.leases[lease.Name]; !ok || stored.ResourceVersion != lease.ResourceVersion {
+		return nil, apierrors.NewConflict(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}, lease.Name, nil)
+	}
+
+	return l.store(lease), nil
+}
+
+func (l *fakeLeases) store(lease *coordinationv1.Lease) *coordinationv1.Lease {
+	l.version++
+
+	stored := lease.DeepCopy()
+	stored.ResourceVersion = strconv.Itoa(l.version)
+	l.leases[lease.Name] = stored
+
+	return stored.DeepCopy()
+}
+
+type fakeClient struct {
+	coordination v1.CoordinationV1Interface
+}
+
+func (c *fakeClient) CoordinationV1() v1.CoordinationV1Interface {
+	return c.coordination
+}
+
+func (c *fakeClient) AddLabel(_ string, _ string, _ string) error {
+	return nil
+}
+
+func (c *fakeClient) RemoveLabel(_ string, _ string) error {
+	return nil
+}
+
+type recordingHandler struct {
+	leader    chan struct{}
+	resigned  chan struct{}
+	followers chan *models.Identity
+}
+
+func (h *recordingHandler) OnBecomeLeader() {
+	h.leader <- struct{}{}
+}
+
+func (h *recordingHandler) OnResignLeader() {
+	h.resigned <- struct{}{}
+}
+
+func (h *recordingHandler) OnBecomeFollower(leaderIdentity *models.Identity) {
+	h.followers <- leaderIdentity
+}
+
+func (h *recordingHandler) OnMetadataWrite(_ error) {}
+
+func newTestElector(client Client, name string) (*leaderElector, *recordingHandler) {
+	c := &config.Dcp{}
+	c.LeaderElection.Config = map[string]string{"leaseLockName": "lease", "leaseLockNamespace": "default"}
+
+	handler := &recordingHandler{
+		leader:    make(chan struct{}, 10),
+		resigned:  make(chan struct{}, 10),
+		followers: make(chan *models.Identity, 10),
+	}
+
+	identity := &models.Identity{IP: "127.0.0.1", Name: name}
+
+	return NewLeaderElector(client, c, identity, handler, helpers.NewBus()).(*leaderElector), handler
+}
+
+func TestLeaderElector_SteppedDownLeaderFollowsTheNextLeader(t *testing.T) {
+	logger.InitDefaultLogger(logger.ERROR)
+
+	client := &fakeClient{coordination: newFakeLeases()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first, firstHandler := newTestElector(client, "first")
+	second, secondHandler := newTestElector(client, "second")
+
+	wait := func(ch <-chan struct{}, what string) {
+		t.Helper()
+
+		select {
+		case <-ch:
+		case <-time.After(3 * _leaseDuration / 2):
+			t.Fatalf("%s is not observed", what)
+		}
+	}
+
+	first.Run(ctx)
+	wait(firstHandler.leader, "first leader")
+
+	second.Run(ctx)
+
+	select {
+	case leader := <-secondHandler.followers:
+		if leader.Name != "first" {
+			t.Fatalf("second follows %v, want first", leader.Name)
+		}
+	case <-time.After(_leaseDuration):
+		t.Fatal("second does not follow the first leader")
+	}
+
+	steppedDown := time.Now()
+	first.StepDown()
+
+	wait(firstHandler.resigned, "resign")
+	wait(secondHandler.leader, "second leader")
+
+	select {
+	case leader := <-firstHandler.followers:
+		if leader.Name != "second" {
+			t.Fatalf("first follows %v, want second", leader.Name)
+		}
+	case <-time.After(_leaseDuration):
+		t.Fatal("stepped down leader does not follow the next leader")
+	}
+
+	if elapsed := time.Since(steppedDown); elapsed >= _leaseDuration {
+		t.Errorf("stepped down leader follows after %v, want before the lease duration", elapsed)
+	}
+
+	select {
+	case <-firstHandler.leader:
+		t.Error("stepped down leader acquires the lease again while the next leader renews it")
+	default:
+	}
+}
@@ -1,6 +1,7 @@
 package kubernetes
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strconv"
@@ -20,6 +21,10 @@ func (s *statefulSetMembership) GetInfo() *membership.Model {
 	return s.info
 }
 
+func (s *statefulSetMembership) GetInfoContext(_ context.Context) (*membership.Model, error) {
+	return s.info, nil
+}
+
 func (s *statefulSetMembership) Close() {
 }
 
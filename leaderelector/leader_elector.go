@@ -8,10 +8,12 @@ import (
 
 type LeaderElector interface {
 	Run(ctx context.Context)
+	StepDown()
 }
 
 type Handler interface {
 	OnBecomeLeader()
 	OnResignLeader()
 	OnBecomeFollower(leaderIdentity *models.Identity)
+	OnMetadataWrite(err error)
 }
@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type dedupLine struct {
+	level string
+	line  string
+	count int
+}
+
+// DedupLogger writes identical lines once per window and then their number of occurrences.
+// The first line is not delayed since errors are logged right before panics.
+type DedupLogger struct {
+	logger Logger
+	lines  map[string]*dedupLine
+	window time.Duration
+	lock   sync.Mutex
+}
+
+func (l *DedupLogger) Debug(message string, args ...interface{}) {
+	l.Log(DEBUG, message, args...)
+}
+
+func (l *DedupLogger) Info(message string, args ...interface{}) {
+	l.Log(INFO, message, args...)
+}
+
+func (l *DedupLogger) Warn(message string, args ...interface{}) {
+	l.Log(WARN, message, args...)
+}
+
+func (l *DedupLogger) Error(message string, args ...interface{}) {
+	l.Log(ERROR, message, args...)
+}
+
+func (l *DedupLogger) Log(level string, message string, args ...interface{}) {
+	line := fmt.Sprintf(message, args...)
+	key := level + line
+
+	l.lock.Lock()
+
+	if existing, ok := l.lines[key]; ok {
+		existing.count++
+		l.lock.Unlock()
+		return
+	}
+
+	l.lines[key] = &dedupLine{level: level, line: line, count: 1}
+	l.lock.Unlock()
+
+	l.logger.Log(level, "%s", line)
+
+	time.AfterFunc(l.window, func() {
+		l.flush(key)
+	})
+}
+
+func (l *DedupLogger) flush(key string) {
+	l.lock.Lock()
+	line := l.lines[key]
+	delete(l.lines, key)
+	l.lock.Unlock()
+
+	if line.count > 1 {
+		l.logger.Log(line.level, "%s (%d occurrences in %v)", line.line, line.count, l.window)
+	}
+}
+
+func NewDedupLogger(logger Logger, window time.Duration) Logger {
+	return &DedupLogger{
+		logger: logger,
+		lines:  map[string]*dedupLine{},
+		window: window,
+	}
+}
@@ -0,0 +1,59 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingLogger struct {
+	Logger
+	lines []string
+	lock  sync.Mutex
+}
+
+func (l *recordingLogger) Log(level string, message string, args ...interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.lines = append(l.lines, level+" "+fmt.Sprintf(message, args...))
+}
+
+func TestDedupLogger_CollapsesConcurrentIdenticalLines(t *testing.T) {
+	recorder := &recordingLogger{}
+	dedup := NewDedupLogger(recorder, 50*time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dedup.Error("error while monitor try to get index: %v", "metadata unreachable")
+		}()
+	}
+	wg.Wait()
+
+	dedup.Warn("another line")
+
+	time.Sleep(100 * time.Millisecond)
+
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+
+	expected := []string{
+		"ERROR error while monitor try to get index: metadata unreachable",
+		"WARN another line",
+		"ERROR error while monitor try to get index: metadata unreachable (10 occurrences in 50ms)",
+	}
+
+	if len(recorder.lines) != len(expected) {
+		t.Fatalf("lines = %v, want %v", recorder.lines, expected)
+	}
+
+	for i := range expected {
+		if recorder.lines[i] != expected[i] {
+			t.Errorf("line %v = %v, want %v", i, recorder.lines[i], expected[i])
+		}
+	}
+}
@@ -1,7 +1,11 @@
 package membership
 
+import "context"
+
 type Membership interface {
 	GetInfo() *Model
+	// GetInfoContext returns ctx.Err() if the model is not received before ctx is done
+	GetInfoContext(ctx context.Context) (*Model, error)
 	Close()
 }
 
@@ -1,6 +1,8 @@
 package membership
 
 import (
+	"context"
+
 	"github.com/Trendyol/go-dcp/config"
 )
 
@@ -12,6 +14,10 @@ func (s *staticMembership) GetInfo() *Model {
 	return s.info
 }
 
+func (s *staticMembership) GetInfoContext(_ context.Context) (*Model, error) {
+	return s.info, nil
+}
+
 func (s *staticMembership) Close() {
 }
 
@@ -48,9 +48,32 @@ func (s *fileMetadata) Load(vbIds []uint16, bucketUUID string) (*wrapper.Concurr
 	return state, exist, nil
 }
 
-func (s *fileMetadata) Clear(_ []uint16) error { //nolint:unused
-	_ = os.Remove(s.fileName)
-	return nil
+// Clear removes the checkpoints of the vBuckets, the file is removed when no checkpoint is left
+func (s *fileMetadata) Clear(vbIds []uint16) error { //nolint:unused
+	file, err := os.ReadFile(s.fileName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	state := map[uint16]*models.CheckpointDocument{}
+	if err = jsoniter.Unmarshal(file, &state); err != nil {
+		return err
+	}
+
+	for _, vbID := range vbIds {
+		delete(state, vbID)
+	}
+
+	if len(state) == 0 {
+		_ = os.Remove(s.fileName)
+		return nil
+	}
+
+	return s.Save(state, nil, "")
 }
 
 func NewFSMetadata(config *config.Dcp) Metadata { //nolint:unused
@@ -1,11 +1,29 @@
 package models
 
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
 type ListenerContext struct {
+	Context context.Context
+	// Err can be set by the listener, ErrRetryAfter invokes the listener with the same event again after the delay
+	Err    error
 	Commit func()
 	Event  interface{}
 	Ack    func()
 }
 
+// ErrRetryAfter holds the event without acking it until the listener is invoked again after the Duration
+type ErrRetryAfter struct {
+	Duration time.Duration
+}
+
+func (e ErrRetryAfter) Error() string {
+	return fmt.Sprintf("retry after %v", e.Duration)
+}
+
 type ListenerArgs struct {
 	Event interface{}
 }
@@ -0,0 +1,18 @@
+package models
+
+type PartitionFunc func(event interface{}) int
+
+func vbIDPartition(event interface{}) int {
+	switch v := event.(type) {
+	case DcpMutation:
+		return int(v.VbID)
+	case DcpDeletion:
+		return int(v.VbID)
+	case DcpExpiration:
+		return int(v.VbID)
+	default:
+		return 0
+	}
+}
+
+var DefaultPartitionFunc PartitionFunc = vbIDPartition
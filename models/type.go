@@ -8,6 +8,7 @@ import (
 
 type Offset struct {
 	*SnapshotMarker
+	// VbUUID is the failover log entry of the opened stream, the same seqNo can be received again with another VbUUID after a failover
 	VbUUID gocbcore.VbUUID
 	SeqNo  uint64
 }
@@ -17,6 +18,40 @@ type PersistSeqNo struct {
 	SeqNo gocbcore.SeqNo
 }
 
+type ConsumerCaughtUp struct {
+	VBucketCount int
+	Duration     time.Duration
+}
+
+type VBucketIdle struct {
+	VbID  uint16
+	SeqNo uint64
+}
+
+type LeaderStepDown struct {
+	Err        error
+	Reason     string
+	FailingFor time.Duration
+}
+
+type Rollback struct {
+	VbID  uint16
+	SeqNo gocbcore.SeqNo
+}
+
+const (
+	MembershipSubsystem = "membership"
+	CheckpointSubsystem = "checkpoint"
+	StreamSubsystem     = "stream"
+	ClientSubsystem     = "client"
+	APISubsystem        = "api"
+)
+
+type SubsystemError struct {
+	Err       error
+	Subsystem string
+}
+
 type SnapshotMarker struct {
 	StartSeqNo uint64
 	EndSeqNo   uint64
@@ -27,6 +62,8 @@ type InternalDcpMutation struct {
 	*gocbcore.DcpMutation
 	Offset         *Offset
 	CollectionName string
+	// SourceNode is the kv address of the active vBucket the event is streamed from, it is empty when unknown
+	SourceNode string
 }
 
 type InternalDcpDeletion struct {
@@ -34,6 +71,7 @@ type InternalDcpDeletion struct {
 	*gocbcore.DcpDeletion
 	Offset         *Offset
 	CollectionName string
+	SourceNode     string
 }
 
 type InternalDcpExpiration struct {
@@ -41,6 +79,7 @@ type InternalDcpExpiration struct {
 	*gocbcore.DcpExpiration
 	Offset         *Offset
 	CollectionName string
+	SourceNode     string
 }
 
 type InternalDcpSeqNoAdvance struct {
@@ -1,6 +1,8 @@
 package servicediscovery
 
 import (
+	"sync/atomic"
+
 	"github.com/Trendyol/go-dcp/models"
 )
 
@@ -24,8 +26,9 @@ type Rebalance struct {
 }
 
 type Service struct {
-	Client Client
-	Name   string
+	Client            Client
+	Name              string
+	rebalanceFailures atomic.Int32
 }
 
 func NewService(client Client, name string) *Service {
@@ -1,10 +1,15 @@
 package servicediscovery
 
 import (
+	"errors"
 	"fmt"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Trendyol/go-dcp/models"
+
 	"github.com/Trendyol/go-dcp/wrapper"
 
 	"github.com/Trendyol/go-dcp/config"
@@ -31,17 +36,28 @@ type ServiceDiscovery interface {
 	SetInfo(memberNumber int, totalMembers int)
 	BeLeader()
 	DontBeLeader()
+	IsLeader() bool
+	ReportCoordination(kind string, err error)
 }
 
+const (
+	CoordinationMetadata  = "metadata"
+	CoordinationFollowers = "followers"
+)
+
+var errNoFollowerReachable = errors.New("no follower is reachable")
+
 type serviceDiscovery struct {
-	bus             helpers.Bus
-	leaderService   *Service
-	services        *wrapper.ConcurrentSwissMap[string, *Service]
-	heartbeatTicker *time.Ticker
-	monitorTicker   *time.Ticker
-	info            *membership.Model
-	config          *config.Dcp
-	amILeader       bool
+	bus                  helpers.Bus
+	leaderService        *Service
+	services             *wrapper.ConcurrentSwissMap[string, *Service]
+	heartbeatTicker      *time.Ticker
+	monitorTicker        *time.Ticker
+	info                 *membership.Model
+	config               *config.Dcp
+	coordinationFailures map[string]time.Time
+	coordinationLock     sync.Mutex
+	amILeader            atomic.Bool
 }
 
 func (s *serviceDiscovery) Add(service *Service) {
@@ -65,11 +81,17 @@ func (s *serviceDiscovery) RemoveAll() {
 }
 
 func (s *serviceDiscovery) BeLeader() {
-	s.amILeader = true
+	s.resetCoordinationFailures()
+	s.amILeader.Store(true)
 }
 
 func (s *serviceDiscovery) DontBeLeader() {
-	s.amILeader = false
+	s.amILeader.Store(false)
+	s.resetCoordinationFailures()
+}
+
+func (s *serviceDiscovery) IsLeader() bool {
+	return s.amILeader.Load()
 }
 
 func (s *serviceDiscovery) AssignLeader(leaderService *Service) {
@@ -147,24 +169,107 @@ func (s *serviceDiscovery) StartMonitor() {
 		time.Sleep(s.config.Dcp.Group.Membership.RebalanceDelay)
 
 		for range s.monitorTicker.C {
-			if !s.amILeader {
-				continue
-			}
+			s.monitor()
+		}
+	}()
+}
 
-			names := s.GetAll()
-			totalMembers := len(names) + 1
+func (s *serviceDiscovery) monitor() {
+	if !s.IsLeader() {
+		return
+	}
 
-			s.SetInfo(1, totalMembers)
+	names := s.GetAll()
+	totalMembers := len(names) + 1
 
-			for index, name := range names {
-				if service, ok := s.services.Load(name); ok {
-					if err := service.Client.Rebalance(index+2, totalMembers); err != nil {
-						logger.Log.Error("rebalance failed for %s", name)
-					}
-				}
+	s.SetInfo(1, totalMembers)
+
+	reached := 0
+
+	for index, name := range names {
+		if service, ok := s.services.Load(name); ok {
+			if err := service.Client.Rebalance(index+2, totalMembers); err != nil {
+				logger.Log.Error("rebalance failed for %s", name)
+				s.markRebalanceFailed(service)
+			} else {
+				service.rebalanceFailures.Store(0)
+				reached++
 			}
 		}
-	}()
+	}
+
+	if len(names) == 0 {
+		return
+	}
+
+	// followers are unreachable for a while when they restart, the leader steps down only when it lasts for stepDownAfter
+	if reached == 0 {
+		logger.Log.Warn("no follower is reachable, %d followers failed to rebalance", len(names))
+		s.ReportCoordination(CoordinationFollowers, errNoFollowerReachable)
+	} else {
+		s.ReportCoordination(CoordinationFollowers, nil)
+	}
+}
+
+func (s *serviceDiscovery) markRebalanceFailed(service *Service) {
+	failures := service.rebalanceFailures.Add(1)
+
+	if int(failures) == s.config.LeaderElection.RPC.MaxRebalanceFailures {
+		s.Remove(service.Name)
+		logger.Log.Info("client %s removed after %v consecutive rebalance failures", service.Name, failures)
+	}
+}
+
+// ReportCoordination tracks the failures of the leader coordination by kind,
+// the leader steps down when a kind keeps failing for leaderElection.stepDownAfter
+func (s *serviceDiscovery) ReportCoordination(kind string, err error) {
+	if !s.IsLeader() {
+		return
+	}
+
+	s.coordinationLock.Lock()
+
+	if err == nil {
+		delete(s.coordinationFailures, kind)
+		s.coordinationLock.Unlock()
+		return
+	}
+
+	now := time.Now()
+
+	since, ok := s.coordinationFailures[kind]
+	if !ok {
+		since = now
+		s.coordinationFailures[kind] = since
+	}
+
+	failingFor := now.Sub(since)
+	stepDownAfter := s.config.LeaderElection.StepDownAfter
+
+	stepDown := stepDownAfter > 0 && failingFor >= stepDownAfter
+	if stepDown {
+		s.coordinationFailures = map[string]time.Time{}
+	}
+
+	s.coordinationLock.Unlock()
+
+	if !stepDown {
+		return
+	}
+
+	logger.Log.Warn("leader steps down after %s coordination failures for %v, err: %v", kind, failingFor, err)
+
+	s.bus.Emit(helpers.LeaderStepDownBusEventName, models.LeaderStepDown{
+		Reason:     kind,
+		FailingFor: failingFor,
+		Err:        err,
+	})
+}
+
+func (s *serviceDiscovery) resetCoordinationFailures() {
+	s.coordinationLock.Lock()
+	s.coordinationFailures = map[string]time.Time{}
+	s.coordinationLock.Unlock()
 }
 
 func (s *serviceDiscovery) StopMonitor() {
@@ -200,8 +305,9 @@ func (s *serviceDiscovery) SetInfo(memberNumber int, totalMembers int) {
 
 func NewServiceDiscovery(config *config.Dcp, bus helpers.Bus) ServiceDiscovery {
 	return &serviceDiscovery{
-		services: wrapper.CreateConcurrentSwissMap[string, *Service](0),
-		bus:      bus,
-		config:   config,
+		services:             wrapper.CreateConcurrentSwissMap[string, *Service](0),
+		bus:                  bus,
+		config:               config,
+		coordinationFailures: map[string]time.Time{},
 	}
 }
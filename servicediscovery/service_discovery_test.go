@@ -0,0 +1,180 @@
+package servicediscovery
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Trendyol/go-dcp/config"
+	"github.com/Trendyol/go-dcp/helpers"
+	"github.com/Trendyol/go-dcp/logger"
+	"github.com/Trendyol/go-dcp/membership"
+	"github.com/Trendyol/go-dcp/models"
+)
+
+type fakeClient struct {
+	rebalanceErr error
+	memberNumber int
+	totalMembers int
+}
+
+func (c *fakeClient) Close() error {
+	return nil
+}
+
+func (c *fakeClient) Ping() error {
+	return nil
+}
+
+func (c *fakeClient) Register() error {
+	return nil
+}
+
+func (c *fakeClient) IsConnected() bool {
+	return true
+}
+
+func (c *fakeClient) Reconnect() error {
+	return nil
+}
+
+func (c *fakeClient) Rebalance(memberNumber int, totalMembers int) error {
+	if c.rebalanceErr != nil {
+		return c.rebalanceErr
+	}
+
+	c.memberNumber = memberNumber
+	c.totalMembers = totalMembers
+
+	return nil
+}
+
+func newTestServiceDiscovery(bus helpers.Bus) *serviceDiscovery {
+	logger.InitDefaultLogger(logger.ERROR)
+
+	c := &config.Dcp{}
+	c.ApplyDefaults()
+
+	s := NewServiceDiscovery(c, bus).(*serviceDiscovery)
+	s.BeLeader()
+
+	return s
+}
+
+func TestServiceDiscovery_UnreachableFollowerIsExcludedFromTotalMembers(t *testing.T) {
+	bus := helpers.NewBus()
+
+	var info *membership.Model
+	bus.Subscribe(helpers.MembershipChangedBusEventName, func(event interface{}) {
+		info = event.(*membership.Model)
+	})
+
+	s := newTestServiceDiscovery(bus)
+
+	healthy := &fakeClient{}
+	unreachable := &fakeClient{rebalanceErr: errors.New("connection refused")}
+
+	s.Add(NewService(healthy, "a"))
+	s.Add(NewService(unreachable, "b"))
+
+	for i := 0; i < s.config.LeaderElection.RPC.MaxRebalanceFailures; i++ {
+		s.monitor()
+	}
+
+	if names := s.GetAll(); len(names) != 1 || names[0] != "a" {
+		t.Fatalf("unreachable follower must be removed, got %v", names)
+	}
+
+	s.monitor()
+
+	if info.TotalMembers != 2 || info.MemberNumber != 1 {
+		t.Errorf("leader info is %v/%v, want 1/2", info.MemberNumber, info.TotalMembers)
+	}
+
+	if healthy.totalMembers != 2 || healthy.memberNumber != 2 {
+		t.Errorf("follower info is %v/%v, want 2/2", healthy.memberNumber, healthy.totalMembers)
+	}
+}
+
+func TestServiceDiscovery_SuccessfulRebalanceResetsFailures(t *testing.T) {
+	s := newTestServiceDiscovery(helpers.NewBus())
+
+	flaky := &fakeClient{rebalanceErr: errors.New("timeout")}
+	s.Add(NewService(flaky, "a"))
+
+	for i := 0; i < s.config.LeaderElection.RPC.MaxRebalanceFailures-1; i++ {
+		s.monitor()
+	}
+
+	flaky.rebalanceErr = nil
+	s.monitor()
+
+	flaky.rebalanceErr = errors.New("timeout")
+	s.monitor()
+
+	if names := s.GetAll(); len(names) != 1 {
+		t.Errorf("follower must not be removed after non consecutive failures, got %v", names)
+	}
+}
+
+func TestServiceDiscovery_ConcurrentRebalanceFailuresRemoveFollowerOnce(t *testing.T) {
+	s := newTestServiceDiscovery(helpers.NewBus())
+
+	service := NewService(&fakeClient{}, "a")
+	s.Add(service)
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.config.LeaderElection.RPC.MaxRebalanceFailures; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.markRebalanceFailed(service)
+		}()
+	}
+	wg.Wait()
+
+	if failures := int(service.rebalanceFailures.Load()); failures != s.config.LeaderElection.RPC.MaxRebalanceFailures {
+		t.Errorf("failures = %v, want %v", failures, s.config.LeaderElection.RPC.MaxRebalanceFailures)
+	}
+
+	if names := s.GetAll(); len(names) != 0 {
+		t.Errorf("follower must be removed, got %v", names)
+	}
+}
+
+func TestServiceDiscovery_LeaderStepsDownWhenNoFollowerIsReachable(t *testing.T) {
+	bus := helpers.NewBus()
+
+	var stepDowns []models.LeaderStepDown
+	bus.Subscribe(helpers.LeaderStepDownBusEventName, func(event interface{}) {
+		stepDowns = append(stepDowns, event.(models.LeaderStepDown))
+	})
+
+	s := newTestServiceDiscovery(bus)
+	s.config.LeaderElection.StepDownAfter = 10 * time.Millisecond
+	s.config.LeaderElection.RPC.MaxRebalanceFailures = 10
+
+	follower := &fakeClient{rebalanceErr: errors.New("connection refused")}
+	s.Add(NewService(follower, "a"))
+
+	s.monitor()
+	time.Sleep(20 * time.Millisecond)
+
+	// a reached follower resets the failing period
+	follower.rebalanceErr = nil
+	s.monitor()
+	follower.rebalanceErr = errors.New("connection refused")
+	s.monitor()
+
+	if len(stepDowns) != 0 {
+		t.Fatalf("step downs = %v, want none before stepDownAfter", stepDowns)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	s.monitor()
+
+	if len(stepDowns) != 1 || stepDowns[0].Reason != CoordinationFollowers {
+		t.Errorf("step downs = %v, want one for the followers", stepDowns)
+	}
+}
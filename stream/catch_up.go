@@ -0,0 +1,77 @@
+package stream
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Trendyol/go-dcp/models"
+)
+
+// catchUpTracker reports once when the vBuckets reach the high seqNos observed at startup
+type catchUpTracker struct {
+	start        time.Time
+	highSeqNos   map[uint16]uint64
+	targets      map[uint16]uint64
+	onCaughtUp   func(event models.ConsumerCaughtUp)
+	lock         sync.Mutex
+	vBucketCount int
+	done         atomic.Bool
+}
+
+func (t *catchUpTracker) Advance(vbID uint16, seqNo uint64) {
+	if t.done.Load() {
+		return
+	}
+
+	t.lock.Lock()
+
+	if target, ok := t.targets[vbID]; ok && seqNo >= target {
+		delete(t.targets, vbID)
+	}
+
+	t.lock.Unlock()
+
+	t.check()
+}
+
+func (t *catchUpTracker) check() {
+	t.lock.Lock()
+	caughtUp := len(t.targets) == 0 && t.done.CompareAndSwap(false, true)
+	t.lock.Unlock()
+
+	if caughtUp {
+		t.onCaughtUp(models.ConsumerCaughtUp{
+			VBucketCount: t.vBucketCount,
+			Duration:     time.Since(t.start),
+		})
+	}
+}
+
+func (t *catchUpTracker) IsDone() bool {
+	return t.done.Load()
+}
+
+func newCatchUpTracker(
+	start time.Time,
+	vbIds []uint16,
+	highSeqNos map[uint16]uint64,
+	offsets map[uint16]uint64,
+	onCaughtUp func(event models.ConsumerCaughtUp),
+) *catchUpTracker {
+	t := &catchUpTracker{
+		start:        start,
+		highSeqNos:   highSeqNos,
+		targets:      map[uint16]uint64{},
+		onCaughtUp:   onCaughtUp,
+		vBucketCount: len(vbIds),
+	}
+
+	for _, vbID := range vbIds {
+		if highSeqNos[vbID] > offsets[vbID] {
+			t.targets[vbID] = highSeqNos[vbID]
+		}
+	}
+
+	return t
+}
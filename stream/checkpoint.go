@@ -1,6 +1,8 @@
 package stream
 
 import (
+	"errors"
+	"fmt"
 	"sync"
 	"time"
 
@@ -14,6 +16,7 @@ import (
 
 	"github.com/Trendyol/go-dcp/models"
 
+	"github.com/Trendyol/go-dcp/helpers"
 	"github.com/Trendyol/go-dcp/logger"
 
 	"github.com/couchbase/gocbcore/v10"
@@ -22,8 +25,16 @@ import (
 const (
 	CheckpointTypeAuto            = "auto"
 	CheckpointAutoResetTypeLatest = "latest"
+
+	PrunedSeqNoPolicyRollbackToAvailable = "rollbackToAvailable"
+	PrunedSeqNoPolicyFail                = "fail"
+	PrunedSeqNoPolicyRestartFromNow      = "restartFromNow"
+	OutOfRangeVBucketPolicyPrune         = "prune"
+	OutOfRangeVBucketPolicyIgnore        = "ignore"
 )
 
+var ErrPrunedSeqNo = errors.New("checkpoint seqNo is pruned")
+
 type Checkpoint interface {
 	Save()
 	Load() (*wrapper.ConcurrentSwissMap[uint16, *models.Offset], *wrapper.ConcurrentSwissMap[uint16, bool], bool)
@@ -42,6 +53,7 @@ type checkpoint struct {
 	stream     Stream
 	client     couchbase.Client
 	metadata   metadata.Metadata
+	bus        helpers.Bus
 	schedule   *time.Ticker
 	config     *config.Dcp
 	saveLock   *sync.Mutex
@@ -106,14 +118,20 @@ func (s *checkpoint) Save() {
 		s.stream.UnmarkDirtyOffsets()
 	} else {
 		logger.Log.Error("error while saving checkpoint document: %v", err)
+		s.bus.Emit(helpers.ErrorOccurredBusEventName, models.SubsystemError{
+			Subsystem: models.CheckpointSubsystem,
+			Err:       err,
+		})
 	}
 }
 
+//nolint:funlen
 func (s *checkpoint) Load() (*wrapper.ConcurrentSwissMap[uint16, *models.Offset], *wrapper.ConcurrentSwissMap[uint16, bool], bool) {
 	s.loadLock.Lock()
 	defer s.loadLock.Unlock()
 
 	dump, exist, err := s.metadata.Load(s.vbIds, s.bucketUUID)
+
 	if err == nil {
 		logger.Log.Debug("loaded checkpoint")
 	} else {
@@ -157,15 +175,41 @@ func (s *checkpoint) Load() (*wrapper.ConcurrentSwissMap[uint16, *models.Offset]
 		return offsets, dirtyOffsets, anyDirtyOffset
 	}
 
+	var purgeSeqNos, seqNoMap map[uint16]uint64
+	if exist && s.config.Checkpoint.PrunedSeqNoPolicy != "" {
+		purgeSeqNos, seqNoMap = s.getSeqNosForPrunedSeqNoPolicy()
+	}
+
 	dump.Range(func(vbID uint16, doc *models.CheckpointDocument) bool {
-		offsets.Store(vbID, &models.Offset{
+		offset := &models.Offset{
 			SnapshotMarker: &models.SnapshotMarker{
 				StartSeqNo: doc.Checkpoint.Snapshot.StartSeqNo,
 				EndSeqNo:   doc.Checkpoint.Snapshot.EndSeqNo,
 			},
 			VbUUID: gocbcore.VbUUID(doc.Checkpoint.VbUUID),
 			SeqNo:  doc.Checkpoint.SeqNo,
-		})
+		}
+
+		if purgeSeqNos != nil {
+			adjusted, err := applyPrunedSeqNoPolicy(s.config.Checkpoint.PrunedSeqNoPolicy, offset, purgeSeqNos[vbID], seqNoMap[vbID])
+			if err != nil {
+				logger.Log.Error("cannot apply pruned seqNo policy, vbID: %d, err: %v", vbID, err)
+				panic(err)
+			}
+
+			if adjusted != offset {
+				logger.Log.Warn(
+					"checkpoint seqNo is pruned, vbID: %d, seqNo: %d, purgeSeqNo: %d, policy: %s, new seqNo: %d",
+					vbID, offset.SeqNo, purgeSeqNos[vbID], s.config.Checkpoint.PrunedSeqNoPolicy, adjusted.SeqNo,
+				)
+
+				offset = adjusted
+				dirtyOffsets.Store(vbID, true)
+				anyDirtyOffset = true
+			}
+		}
+
+		offsets.Store(vbID, offset)
 
 		return true
 	})
@@ -173,6 +217,55 @@ func (s *checkpoint) Load() (*wrapper.ConcurrentSwissMap[uint16, *models.Offset]
 	return offsets, dirtyOffsets, anyDirtyOffset
 }
 
+func (s *checkpoint) getSeqNosForPrunedSeqNoPolicy() (map[uint16]uint64, map[uint16]uint64) {
+	purgeSeqNos, err := s.client.GetVBucketPurgeSeqNos()
+	if err != nil {
+		logger.Log.Error("error while getting vbucket purge seqNos: %v", err)
+		panic(err)
+	}
+
+	if s.config.Checkpoint.PrunedSeqNoPolicy != PrunedSeqNoPolicyRestartFromNow {
+		return purgeSeqNos, nil
+	}
+
+	seqNoMap, err := s.client.GetVBucketSeqNos()
+	if err != nil {
+		logger.Log.Error("error while getting vbucket seqNos: %v", err)
+		panic(err)
+	}
+
+	return purgeSeqNos, seqNoMap
+}
+
+// applyPrunedSeqNoPolicy returns the given offset as is when its seqNo is still retained by the server
+func applyPrunedSeqNoPolicy(policy string, offset *models.Offset, purgeSeqNo uint64, currentSeqNo uint64) (*models.Offset, error) {
+	if offset.SeqNo == 0 || offset.SeqNo >= purgeSeqNo {
+		return offset, nil
+	}
+
+	var seqNo uint64
+
+	switch policy {
+	case PrunedSeqNoPolicyRollbackToAvailable:
+		seqNo = purgeSeqNo
+	case PrunedSeqNoPolicyRestartFromNow:
+		seqNo = currentSeqNo
+	case PrunedSeqNoPolicyFail:
+		return nil, fmt.Errorf("%w: seqNo %d is older than purge seqNo %d", ErrPrunedSeqNo, offset.SeqNo, purgeSeqNo)
+	default:
+		return nil, fmt.Errorf("unknown pruned seqNo policy: %s", policy)
+	}
+
+	return &models.Offset{
+		SnapshotMarker: &models.SnapshotMarker{
+			StartSeqNo: seqNo,
+			EndSeqNo:   seqNo,
+		},
+		VbUUID: offset.VbUUID,
+		SeqNo:  seqNo,
+	}, nil
+}
+
 func (s *checkpoint) Clear() {
 	_ = s.metadata.Clear(s.vbIds)
 	logger.Log.Debug("cleared checkpoint")
@@ -183,12 +276,14 @@ func (s *checkpoint) StartSchedule() {
 		return
 	}
 
-	go func() {
-		s.schedule = time.NewTicker(s.config.Checkpoint.Interval)
-		for range s.schedule.C {
+	// the ticker is created before the goroutine so stop schedule never misses it
+	s.schedule = time.NewTicker(s.config.Checkpoint.Interval)
+
+	go func(schedule *time.Ticker) {
+		for range schedule.C {
 			s.Save()
 		}
-	}()
+	}(s.schedule)
 
 	logger.Log.Debug("started checkpoint schedule")
 }
@@ -210,13 +305,13 @@ func (s *checkpoint) GetMetric() *CheckpointMetric {
 }
 
 func getBucketUUID(client couchbase.Client) string {
-	snapshot, err := client.GetConfigSnapshot()
+	bucketUUID, err := client.GetBucketUUID()
 	if err != nil {
 		logger.Log.Error("failed to get config snapshot: %v", err)
 		panic(err)
 	}
 
-	return snapshot.BucketUUID()
+	return bucketUUID
 }
 
 func NewCheckpoint(
@@ -225,10 +320,12 @@ func NewCheckpoint(
 	client couchbase.Client,
 	metadata metadata.Metadata,
 	config *config.Dcp,
+	bus helpers.Bus,
 ) Checkpoint {
 	return &checkpoint{
 		client:     client,
 		stream:     stream,
+		bus:        bus,
 		vbIds:      vbIds,
 		bucketUUID: getBucketUUID(client),
 		metadata:   metadata,
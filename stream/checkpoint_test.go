@@ -0,0 +1,119 @@
+package stream
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/Trendyol/go-dcp/couchbase"
+	"github.com/Trendyol/go-dcp/models"
+	"github.com/Trendyol/go-dcp/wrapper"
+)
+
+type fakeMetadata struct {
+	docs    map[uint16]*models.CheckpointDocument
+	cleared []uint16
+}
+
+func (m *fakeMetadata) Save(_ map[uint16]*models.CheckpointDocument, _ map[uint16]bool, _ string) error {
+	return nil
+}
+
+func (m *fakeMetadata) Load(_ []uint16, _ string) (*wrapper.ConcurrentSwissMap[uint16, *models.CheckpointDocument], bool, error) {
+	state := wrapper.CreateConcurrentSwissMap[uint16, *models.CheckpointDocument](1024)
+
+	for vbID, doc := range m.docs {
+		state.Store(vbID, doc)
+	}
+
+	return state, true, nil
+}
+
+func (m *fakeMetadata) Clear(vbIds []uint16) error {
+	m.cleared = append(m.cleared, vbIds...)
+	return nil
+}
+
+type fakeSeqNoClient struct {
+	couchbase.Client
+	purgeSeqNos map[uint16]uint64
+	seqNos      map[uint16]uint64
+}
+
+func (c *fakeSeqNoClient) GetVBucketPurgeSeqNos() (map[uint16]uint64, error) {
+	return c.purgeSeqNos, nil
+}
+
+func (c *fakeSeqNoClient) GetVBucketSeqNos() (map[uint16]uint64, error) {
+	return c.seqNos, nil
+}
+
+func newCheckpointDocument(seqNo uint64) *models.CheckpointDocument {
+	doc := models.NewEmptyCheckpointDocument("uuid")
+	doc.Checkpoint.VbUUID = 42
+	doc.Checkpoint.SeqNo = seqNo
+	doc.Checkpoint.Snapshot.StartSeqNo = seqNo
+	doc.Checkpoint.Snapshot.EndSeqNo = seqNo
+
+	return doc
+}
+
+func loadPrunedCheckpoint(policy string) (*wrapper.ConcurrentSwissMap[uint16, *models.Offset], bool) {
+	c := newTestConfig()
+	c.Checkpoint.PrunedSeqNoPolicy = policy
+
+	s := &checkpoint{
+		client: &fakeSeqNoClient{
+			purgeSeqNos: map[uint16]uint64{0: 100, 1: 100},
+			seqNos:      map[uint16]uint64{0: 1000, 1: 1000},
+		},
+		metadata: &fakeMetadata{
+			docs: map[uint16]*models.CheckpointDocument{0: newCheckpointDocument(10), 1: newCheckpointDocument(150)},
+		},
+		vbIds:    []uint16{0, 1},
+		config:   c,
+		loadLock: &sync.Mutex{},
+		metric:   &CheckpointMetric{},
+	}
+
+	offsets, _, anyDirtyOffset := s.Load()
+
+	return offsets, anyDirtyOffset
+}
+
+func TestCheckpoint_LoadAppliesPrunedSeqNoPolicy(t *testing.T) {
+	for policy, expected := range map[string]uint64{
+		"":                                   10,
+		PrunedSeqNoPolicyRollbackToAvailable: 100,
+		PrunedSeqNoPolicyRestartFromNow:      1000,
+	} {
+		offsets, anyDirtyOffset := loadPrunedCheckpoint(policy)
+
+		pruned, _ := offsets.Load(0)
+		if pruned.SeqNo != expected || pruned.StartSeqNo != expected || pruned.VbUUID != 42 {
+			t.Errorf("policy %q: pruned offset = %v, snapshot = %v, want seqNo %v", policy, pruned, pruned.SnapshotMarker, expected)
+		}
+
+		if anyDirtyOffset != (policy != "") {
+			t.Errorf("policy %q: anyDirtyOffset = %v", policy, anyDirtyOffset)
+		}
+
+		retained, _ := offsets.Load(1)
+		if retained.SeqNo != 150 {
+			t.Errorf("policy %q: retained offset seqNo = %v, want 150", policy, retained.SeqNo)
+		}
+	}
+}
+
+func TestCheckpoint_LoadFailsOnPrunedSeqNoWithFailPolicy(t *testing.T) {
+	defer func() {
+		err, _ := recover().(error)
+		if !errors.Is(err, ErrPrunedSeqNo) {
+			t.Errorf("recovered %v, want %v", err, ErrPrunedSeqNo)
+		}
+	}()
+
+	loadPrunedCheckpoint(PrunedSeqNoPolicyFail)
+
+	t.Errorf("load must fail")
+}
@@ -0,0 +1,111 @@
+package stream
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/Trendyol/go-dcp/config"
+	"github.com/Trendyol/go-dcp/models"
+)
+
+// _fanOutSinkBacklog is the count of events a listener can lag behind the faster listeners
+const _fanOutSinkBacklog = 128
+
+type fanOutSink struct {
+	listener models.Listener
+	backlog  chan struct{}
+	last     chan struct{}
+	lock     sync.Mutex
+}
+
+// dispatch invokes the listener after it returns from the previous event, so the events of a listener stay in order
+func (s *fanOutSink) dispatch(parent *models.ListenerContext, ack func(), returned chan<- error) bool {
+	select {
+	case s.backlog <- struct{}{}:
+	case <-parent.Context.Done():
+		return false
+	}
+
+	done := make(chan struct{})
+
+	s.lock.Lock()
+	previous := s.last
+	s.last = done
+	s.lock.Unlock()
+
+	go func() {
+		defer func() { <-s.backlog }()
+		defer close(done)
+
+		<-previous
+
+		var once sync.Once
+
+		ctx := &models.ListenerContext{
+			Context: parent.Context,
+			Commit:  parent.Commit,
+			Event:   parent.Event,
+			Ack: func() {
+				once.Do(ack)
+			},
+		}
+
+		// ErrRetryAfter redelivers the event only to this listener
+		invokeWithRetry(s.listener, ctx)
+
+		returned <- ctx.Err
+	}()
+
+	return true
+}
+
+// NewFanOutListener invokes every listener concurrently with each event, the event is acked by the ack policy.
+// It returns when the listeners required by the ack policy return, a slower listener continues with its events in order
+func NewFanOutListener(ackPolicy string, listeners ...models.Listener) models.Listener {
+	required := len(listeners)
+	if ackPolicy == config.FanOutAckPolicyAny {
+		required = 1
+	}
+
+	sinks := make([]*fanOutSink, 0, len(listeners))
+	for _, listener := range listeners {
+		last := make(chan struct{})
+		close(last)
+
+		sinks = append(sinks, &fanOutSink{
+			listener: listener,
+			backlog:  make(chan struct{}, _fanOutSinkBacklog),
+			last:     last,
+		})
+	}
+
+	return func(ctx *models.ListenerContext) {
+		var acks atomic.Int32
+
+		ack := func() {
+			if acks.Add(1) == int32(required) {
+				ctx.Ack()
+			}
+		}
+
+		returned := make(chan error, len(sinks))
+
+		dispatched := 0
+		for _, sink := range sinks {
+			if sink.dispatch(ctx, ack, returned) {
+				dispatched++
+			}
+		}
+
+		wait := required
+		if dispatched < wait {
+			wait = dispatched
+		}
+
+		for i := 0; i < wait; i++ {
+			if err := <-returned; err != nil && ctx.Err == nil {
+				ctx.Err = err
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package stream
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Trendyol/go-dcp/helpers"
+)
+
+const _idleCheckDivisor = 4
+
+// idleTracker reports the vBuckets without events for the interval, repeatedly while they stay idle.
+// It lives as long as the stream and is started with the vBuckets of every open
+type idleTracker struct {
+	lastEvents map[uint16]time.Time
+	onIdle     func(vbID uint16)
+	goroutines *helpers.Goroutines
+	stopCh     chan struct{}
+	interval   time.Duration
+	lock       sync.Mutex
+}
+
+func (t *idleTracker) Touch(vbID uint16) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if _, ok := t.lastEvents[vbID]; ok {
+		t.lastEvents[vbID] = time.Now()
+	}
+}
+
+func (t *idleTracker) check(now time.Time) {
+	var idle []uint16
+
+	t.lock.Lock()
+
+	for vbID, lastEvent := range t.lastEvents {
+		if now.Sub(lastEvent) >= t.interval {
+			idle = append(idle, vbID)
+			// the next report is after another interval
+			t.lastEvents[vbID] = now
+		}
+	}
+
+	t.lock.Unlock()
+
+	for _, vbID := range idle {
+		t.onIdle(vbID)
+	}
+}
+
+func (t *idleTracker) Start(vbIds []uint16) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.lastEvents = make(map[uint16]time.Time, len(vbIds))
+
+	now := time.Now()
+	for _, vbID := range vbIds {
+		t.lastEvents[vbID] = now
+	}
+
+	stopCh := make(chan struct{})
+	t.stopCh = stopCh
+
+	ticker := time.NewTicker(t.interval / _idleCheckDivisor)
+	tracked := t.goroutines.Start("stream-idle")
+
+	go func() {
+		defer tracked.Stop()
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				t.check(now)
+				tracked.Touch()
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+}
+
+func (t *idleTracker) Stop() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.stopCh != nil {
+		close(t.stopCh)
+		t.stopCh = nil
+	}
+
+	t.lastEvents = map[uint16]time.Time{}
+}
+
+func newIdleTracker(interval time.Duration, onIdle func(vbID uint16), goroutines *helpers.Goroutines) *idleTracker {
+	return &idleTracker{
+		lastEvents: map[uint16]time.Time{},
+		onIdle:     onIdle,
+		goroutines: goroutines,
+		interval:   interval,
+	}
+}
@@ -30,6 +30,7 @@ type LeaderElection interface {
 type leaderElection struct {
 	rpcServer        servicediscovery.Server
 	serviceDiscovery servicediscovery.ServiceDiscovery
+	elector          leaderelector.LeaderElector
 	bus              helpers.Bus
 	myIdentity       *models.Identity
 	config           *config.Dcp
@@ -39,11 +40,15 @@ type leaderElection struct {
 func (l *leaderElection) OnBecomeLeader() {
 	l.serviceDiscovery.BeLeader()
 	l.serviceDiscovery.RemoveLeader()
+
+	l.bus.Emit(helpers.LeaderAcquiredBusEventName, l.myIdentity)
 }
 
 func (l *leaderElection) OnResignLeader() {
 	l.serviceDiscovery.DontBeLeader()
 	l.serviceDiscovery.RemoveAll()
+
+	l.bus.Emit(helpers.LeaderLostBusEventName, l.myIdentity)
 }
 
 func (l *leaderElection) OnBecomeFollower(leaderIdentity *models.Identity) {
@@ -54,6 +59,8 @@ func (l *leaderElection) OnBecomeFollower(leaderIdentity *models.Identity) {
 	l.serviceDiscovery.RemoveAll()
 	l.serviceDiscovery.RemoveLeader()
 
+	l.bus.Emit(helpers.LeaderLostBusEventName, l.myIdentity)
+
 	leaderClient, err := servicediscovery.NewClient(l.config.LeaderElection.RPC.Port, l.myIdentity, leaderIdentity)
 	if err != nil {
 		return
@@ -70,18 +77,26 @@ func (l *leaderElection) OnBecomeFollower(leaderIdentity *models.Identity) {
 	}
 }
 
+func (l *leaderElection) OnMetadataWrite(err error) {
+	l.serviceDiscovery.ReportCoordination(servicediscovery.CoordinationMetadata, err)
+}
+
+func (l *leaderElection) leaderStepDownListener(_ interface{}) {
+	if l.elector != nil {
+		l.elector.StepDown()
+	}
+}
+
 func (l *leaderElection) Start() {
 	l.rpcServer = servicediscovery.NewServer(l.config.LeaderElection.RPC.Port, l.myIdentity, l.serviceDiscovery)
 	l.rpcServer.Listen()
 
-	var elector leaderelector.LeaderElector
-
 	if l.config.LeaderElection.Type == KubernetesLeaderElectionType {
 		kubernetesClient := kubernetes.NewClient(l.myIdentity)
-		elector = kubernetes.NewLeaderElector(kubernetesClient, l.config, l.myIdentity, l, l.bus)
+		l.elector = kubernetes.NewLeaderElector(kubernetesClient, l.config, l.myIdentity, l, l.bus)
 	}
 
-	elector.Run(context.Background())
+	l.elector.Run(context.Background())
 }
 
 func (l *leaderElection) Stop() {
@@ -93,11 +108,15 @@ func NewLeaderElection(
 	serviceDiscovery servicediscovery.ServiceDiscovery,
 	bus helpers.Bus,
 ) LeaderElection {
-	return &leaderElection{
+	l := &leaderElection{
 		config:           config,
 		serviceDiscovery: serviceDiscovery,
 		newLeaderLock:    &sync.Mutex{},
 		myIdentity:       models.NewIdentityFromEnv(),
 		bus:              bus,
 	}
+
+	bus.Subscribe(helpers.LeaderStepDownBusEventName, l.leaderStepDownListener)
+
+	return l
 }
@@ -0,0 +1,87 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Trendyol/go-dcp/helpers"
+	"github.com/Trendyol/go-dcp/models"
+	"github.com/Trendyol/go-dcp/servicediscovery"
+)
+
+type fakeLease struct {
+	candidates []*leaderElection
+	holder     int
+}
+
+type fakeElector struct {
+	lease *fakeLease
+}
+
+func (e *fakeElector) Run(_ context.Context) {}
+
+// StepDown passes the lease to the next candidate like a released kubernetes lease
+func (e *fakeElector) StepDown() {
+	resigned := e.lease.candidates[e.lease.holder]
+	e.lease.holder = (e.lease.holder + 1) % len(e.lease.candidates)
+
+	resigned.OnResignLeader()
+	e.lease.candidates[e.lease.holder].OnBecomeLeader()
+}
+
+func newTestLeaderElection(lease *fakeLease, bus helpers.Bus) *leaderElection {
+	c := newTestConfig()
+	c.LeaderElection.StepDownAfter = time.Nanosecond
+
+	l := NewLeaderElection(c, servicediscovery.NewServiceDiscovery(c, bus), bus).(*leaderElection)
+	l.elector = &fakeElector{lease: lease}
+
+	lease.candidates = append(lease.candidates, l)
+
+	return l
+}
+
+func TestLeaderElection_LeaderStepsDownOnPersistentMetadataWriteFailures(t *testing.T) {
+	lease := &fakeLease{}
+
+	leaderBus := helpers.NewBus()
+	leader := newTestLeaderElection(lease, leaderBus)
+
+	followerBus := helpers.NewBus()
+	follower := newTestLeaderElection(lease, followerBus)
+
+	var stepDowns []models.LeaderStepDown
+	leaderBus.Subscribe(helpers.LeaderStepDownBusEventName, func(event interface{}) {
+		stepDowns = append(stepDowns, event.(models.LeaderStepDown))
+	})
+
+	acquired := false
+	followerBus.Subscribe(helpers.LeaderAcquiredBusEventName, func(_ interface{}) {
+		acquired = true
+	})
+
+	leader.OnBecomeLeader()
+
+	for i := 0; i < 5; i++ {
+		time.Sleep(time.Millisecond)
+		leader.OnMetadataWrite(errors.New("pods is forbidden"))
+	}
+
+	if len(stepDowns) != 1 {
+		t.Fatalf("leader stepped down %v times, want 1", len(stepDowns))
+	}
+
+	if stepDowns[0].Reason != servicediscovery.CoordinationMetadata {
+		t.Errorf("step down reason = %v, want %v", stepDowns[0].Reason, servicediscovery.CoordinationMetadata)
+	}
+
+	if leader.serviceDiscovery.IsLeader() {
+		t.Error("leader must step down")
+	}
+
+	if !acquired || !follower.serviceDiscovery.IsLeader() {
+		t.Error("another member must take over the leadership")
+	}
+}
@@ -0,0 +1,63 @@
+package stream
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Trendyol/go-dcp/logger"
+	"github.com/Trendyol/go-dcp/models"
+)
+
+// orderValidator checks that the listener is invoked with strictly increasing seqNos per vBucket, it is enabled in debug mode
+// to catch the reordering of the worker pools, perVBucketConcurrency invokes the events of a vBucket concurrently on purpose
+type orderValidator struct {
+	lastSeqNos map[uint16]uint64
+	violated   func(err error)
+	lock       sync.Mutex
+}
+
+func (v *orderValidator) Check(vbID uint16, seqNo uint64) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if last, ok := v.lastSeqNos[vbID]; ok && seqNo <= last {
+		return fmt.Errorf("event ordering violation on vbID: %d, seqNo: %d is received after seqNo: %d", vbID, seqNo, last)
+	}
+
+	v.lastSeqNos[vbID] = seqNo
+
+	return nil
+}
+
+func (v *orderValidator) Validate(vbID uint16, seqNo uint64) {
+	if err := v.Check(vbID, seqNo); err != nil {
+		logger.Log.Error("%v", err)
+		v.violated(err)
+	}
+}
+
+func (v *orderValidator) Reset() {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	v.lastSeqNos = map[uint16]uint64{}
+}
+
+// rollbackListener forgets the vBucket since the reopened stream starts again from the rollback seqNo
+func (v *orderValidator) rollbackListener(event interface{}) {
+	rollback := event.(models.Rollback)
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	delete(v.lastSeqNos, rollback.VbID)
+}
+
+func newOrderValidator() *orderValidator {
+	return &orderValidator{
+		lastSeqNos: map[uint16]uint64{},
+		violated: func(err error) {
+			panic(err)
+		},
+	}
+}
@@ -0,0 +1,57 @@
+package stream
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Trendyol/go-dcp/logger"
+)
+
+// startBarrier holds the dispatch until the membership is not changed for the stable period
+type startBarrier struct {
+	timer   *time.Timer
+	open    chan struct{}
+	period  time.Duration
+	lock    sync.Mutex
+	started bool
+}
+
+func (b *startBarrier) release() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !b.started {
+		b.started = true
+		close(b.open)
+		logger.Log.Info("membership is stable for %v, processing is starting", b.period)
+	}
+}
+
+func (b *startBarrier) membershipChangedListener(_ interface{}) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !b.started {
+		b.timer.Reset(b.period)
+	}
+}
+
+func (b *startBarrier) Wait(ctx context.Context) error {
+	select {
+	case <-b.open:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newStartBarrier(period time.Duration) *startBarrier {
+	b := &startBarrier{
+		open:   make(chan struct{}),
+		period: period,
+	}
+	b.timer = time.AfterFunc(period, b.release)
+
+	return b
+}
@@ -2,7 +2,10 @@ package stream
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Trendyol/go-dcp/wrapper"
@@ -23,21 +26,47 @@ type Stream interface {
 	Open()
 	Rebalance()
 	Save()
+	Stop()
+	Drain()
 	Close()
 	GetOffsets() (*wrapper.ConcurrentSwissMap[uint16, *models.Offset], *wrapper.ConcurrentSwissMap[uint16, bool], bool)
 	GetObserver() couchbase.Observer
 	GetMetric() *Metric
 	UnmarkDirtyOffsets()
 	GetCheckpointMetric() *CheckpointMetric
+	GetRateLimiter() *helpers.RateLimiter
 }
 
+const (
+	_drainCheckInterval = 10 * time.Millisecond
+	// _maxVBucketCount is the vBucket count of the buckets on linux, it is lower on macOS or with magma
+	_maxVBucketCount = 1024
+)
+
+var _snapshotSizeBuckets = []float64{1, 10, 100, 1000, 10000, 100000, 1000000}
+
 type Metric struct {
-	ProcessLatency int64
-	DcpLatency     int64
-	Rebalance      int
+	SnapshotSize                 *helpers.Histogram
+	Rebalance                    int
+	CaughtUp                     atomic.Bool
+	StartupCheckpointLoadSeconds float64
+	processLatency               atomic.Int64
+	dcpLatency                   atomic.Int64
+}
+
+// GetProcessLatency returns the listener duration of the latest event in milliseconds
+func (m *Metric) GetProcessLatency() int64 {
+	return m.processLatency.Load()
+}
+
+// GetDcpLatency returns the latency of the latest consumed dcp event in milliseconds
+func (m *Metric) GetDcpLatency() int64 {
+	return m.dcpLatency.Load()
 }
 
 type stream struct {
+	ctx                        context.Context
+	streamCtx                  context.Context
 	client                     couchbase.Client
 	metadata                   metadata.Metadata
 	checkpoint                 Checkpoint
@@ -46,7 +75,21 @@ type stream struct {
 	vBucketDiscovery           VBucketDiscovery
 	bus                        helpers.Bus
 	eventHandler               models.EventHandler
+	partitionFunc              models.PartitionFunc
+	workerPool                 *workerPool
+	collectionWorkerPools      map[string]*workerPool
+	rateLimiter                *helpers.RateLimiter
+	goroutines                 *helpers.Goroutines
+	startBarrier               *startBarrier
+	catchUp                    *catchUpTracker
+	idle                       *idleTracker
+	orderValidator             *orderValidator
+	vBucketProcessors          map[uint16]*vBucketProcessor
+	vBucketProcessorsLock      sync.Mutex
+	pendingAcks                chan struct{}
+	inFlight                   sync.WaitGroup
 	stopCh                     chan struct{}
+	streamCancel               context.CancelFunc
 	finishStreamWithCloseCh    chan struct{}
 	rebalanceTimer             *time.Timer
 	dirtyOffsets               *wrapper.ConcurrentSwissMap[uint16, bool]
@@ -57,71 +100,442 @@ type stream struct {
 	collectionIDs              map[uint32]string
 	offsets                    *wrapper.ConcurrentSwissMap[uint16, *models.Offset]
 	activeStreams              int
+	inProcess                  atomic.Int64
 	rebalanceLock              sync.Mutex
 	anyDirtyOffset             bool
 	balancing                  bool
+	outOfRangePruned           bool
+	stopped                    bool
+	checkpointLoaded           bool
 }
 
 func (s *stream) setOffset(vbID uint16, offset *models.Offset, dirty bool) {
 	s.offsets.Store(vbID, offset)
 	s.dirtyOffsets.Store(vbID, dirty)
+
+	if s.catchUp != nil {
+		s.catchUp.Advance(vbID, offset.SeqNo)
+	}
+}
+
+// loadCheckpoint loads the offsets, rebalances load them again but only the startup load duration is recorded
+func (s *stream) loadCheckpoint() {
+	start := time.Now()
+
+	s.offsets, s.dirtyOffsets, s.anyDirtyOffset = s.checkpoint.Load()
+
+	if !s.checkpointLoaded {
+		s.metric.StartupCheckpointLoadSeconds = time.Since(start).Seconds()
+		s.checkpointLoaded = true
+	}
+}
+
+func (s *stream) openCatchUpTracker(vbIds []uint16) {
+	if s.catchUp != nil && s.catchUp.IsDone() {
+		return
+	}
+
+	// the reopened streams keep the start and the high seqNos of the first open
+	start := time.Now()
+	var highSeqNos map[uint16]uint64
+
+	if s.catchUp != nil {
+		start = s.catchUp.start
+		highSeqNos = s.catchUp.highSeqNos
+	} else {
+		var err error
+		if highSeqNos, err = s.client.GetVBucketSeqNos(); err != nil {
+			logger.Log.Error("error while getting vbucket seqNos for catch up tracking: %v", err)
+			return
+		}
+	}
+
+	offsets := map[uint16]uint64{}
+	s.offsets.Range(func(vbID uint16, offset *models.Offset) bool {
+		offsets[vbID] = offset.SeqNo
+		return true
+	})
+
+	s.catchUp = newCatchUpTracker(start, vbIds, highSeqNos, offsets, s.caughtUp)
+	s.catchUp.check()
+}
+
+func (s *stream) caughtUp(event models.ConsumerCaughtUp) {
+	s.metric.CaughtUp.Store(true)
+
+	logger.Log.Info("consumer caught up with %v vBuckets in %v", event.VBucketCount, event.Duration)
+	s.bus.Emit(helpers.ConsumerCaughtUpBusEventName, event)
+}
+
+func (s *stream) vBucketIdle(vbID uint16) {
+	event := models.VBucketIdle{VbID: vbID}
+	if offset, ok := s.offsets.Load(vbID); ok {
+		event.SeqNo = offset.SeqNo
+	}
+
+	s.bus.Emit(helpers.VBucketIdleBusEventName, event)
+}
+
+func (s *stream) getVBucketProcessor(vbID uint16) *vBucketProcessor {
+	s.vBucketProcessorsLock.Lock()
+	defer s.vBucketProcessorsLock.Unlock()
+
+	if s.vBucketProcessors == nil {
+		return nil
+	}
+
+	processor, ok := s.vBucketProcessors[vbID]
+	if !ok {
+		processor = newVBucketProcessor(
+			s.streamCtx, s.config.Dcp.Processing.PerVBucketConcurrency, s.pendingAcks,
+			s.goroutines.Start(fmt.Sprintf("vbucket-processor-%d", vbID)),
+		)
+		s.vBucketProcessors[vbID] = processor
+	}
+
+	return processor
+}
+
+func (s *stream) stopVBucketProcessors(processors map[uint16]*vBucketProcessor) {
+	s.vBucketProcessorsLock.Lock()
+	defer s.vBucketProcessorsLock.Unlock()
+
+	for _, processor := range processors {
+		processor.Stop()
+	}
+}
+
+// rollbackListener receives the rollbacks while the streams are opened. On the reopen after a rebalance
+// the processors of the previous streams are kept until the streams are opened, so their in-flight events
+// are drained and their acks after the rollback seqNo do not overwrite the loaded checkpoint
+func (s *stream) rollbackListener(event interface{}) {
+	rollback := event.(models.Rollback)
+	drain := s.config.Dcp.Processing.DrainOnRollback
+
+	s.vBucketProcessorsLock.Lock()
+	processor, ok := s.vBucketProcessors[rollback.VbID]
+	if ok && drain {
+		// next events of the vBucket start with a new processor
+		delete(s.vBucketProcessors, rollback.VbID)
+	}
+	s.vBucketProcessorsLock.Unlock()
+
+	if !ok {
+		return
+	}
+
+	// rolled back events are redelivered, they must not hold the pending ack slots
+	processor.freeSlots(processor.tracker.Discard(uint64(rollback.SeqNo)))
+
+	if !drain {
+		return
+	}
+
+	logger.Log.Info("draining in-flight events on rollback, vbID: %d, seqNo: %d", rollback.VbID, rollback.SeqNo)
+
+	processor.Drain()
 }
 
 func (s *stream) waitAndForward(payload interface{}, offset *models.Offset, vbID uint16, eventTime time.Time) {
+	processor := s.getVBucketProcessor(vbID)
+
 	if helpers.IsMetadata(payload) {
-		s.setOffset(vbID, offset, false)
+		if processor != nil {
+			processor.tracker.Skip(offset, func(offset *models.Offset) {
+				s.setOffset(vbID, offset, false)
+			})
+		} else {
+			s.setOffset(vbID, offset, false)
+		}
+		return
+	}
+
+	if s.startBarrier != nil {
+		if err := s.startBarrier.Wait(s.streamCtx); err != nil {
+			return
+		}
+	}
+
+	if err := s.rateLimiter.Wait(s.streamCtx); err != nil {
 		return
 	}
 
-	s.metric.DcpLatency = time.Since(eventTime).Milliseconds()
+	s.metric.dcpLatency.Store(time.Since(eventTime).Milliseconds())
+
+	ack := func() {
+		s.setOffset(vbID, offset, true)
+		s.anyDirtyOffset = true
+	}
+
+	if processor != nil {
+		// blocks consuming until the listener acks enough of the previous events
+		pending, ok := processor.Track(offset)
+		if !ok {
+			return
+		}
+
+		ack = func() {
+			freed := processor.tracker.Ack(pending, func(offset *models.Offset) {
+				s.setOffset(vbID, offset, true)
+				s.anyDirtyOffset = true
+			})
+
+			if freed {
+				processor.freeSlots(1)
+			}
+		}
+	}
 
 	ctx := &models.ListenerContext{
-		Commit: s.checkpoint.Save,
-		Event:  payload,
-		Ack: func() {
-			s.setOffset(vbID, offset, true)
-			s.anyDirtyOffset = true
-		},
+		Context: s.streamCtx,
+		Commit:  s.checkpoint.Save,
+		Event:   payload,
+		Ack:     ack,
 	}
 
-	start := time.Now()
+	if processor != nil {
+		ctx.Context = processor.ctx
+		processor.inFlight.Add(1)
+	}
+
+	s.inProcess.Add(1)
+
+	process := func() {
+		defer s.inProcess.Add(-1)
+
+		if processor != nil {
+			defer processor.inFlight.Done()
+		}
+
+		if s.orderValidator != nil {
+			s.orderValidator.Validate(vbID, offset.SeqNo)
+		}
+
+		start := time.Now()
+
+		s.invokeListener(ctx)
+
+		s.metric.processLatency.Store(time.Since(start).Milliseconds())
+
+		if processor != nil {
+			processor.tracked.Touch()
+		}
+	}
+
+	pool := s.getWorkerPool(payload)
+
+	switch {
+	case processor != nil && s.config.Dcp.Processing.PerVBucketConcurrency > 1:
+		processor.semaphore <- struct{}{}
+		s.inFlight.Add(1)
+
+		go func() {
+			defer s.inFlight.Done()
+			defer func() { <-processor.semaphore }()
+
+			process()
+		}()
+	case pool != nil:
+		pool.Dispatch(payload, process)
+	default:
+		process()
+	}
+}
+
+func (s *stream) invokeListener(ctx *models.ListenerContext) {
+	invokeWithRetry(s.listener, ctx)
+}
+
+// invokeWithRetry invokes the listener with the same event again while it sets ErrRetryAfter, the event is not acked meanwhile
+func invokeWithRetry(listener models.Listener, ctx *models.ListenerContext) {
+	for {
+		listener(ctx)
+
+		var retryAfter models.ErrRetryAfter
+		if !errors.As(ctx.Err, &retryAfter) {
+			return
+		}
+
+		ctx.Err = nil
+
+		select {
+		case <-time.After(retryAfter.Duration):
+		case <-ctx.Context.Done():
+			return
+		}
+	}
+}
 
-	s.listener(ctx)
+func (s *stream) getWorkerPool(payload interface{}) *workerPool {
+	var collectionName string
 
-	s.metric.ProcessLatency = time.Since(start).Milliseconds()
+	switch v := payload.(type) {
+	case models.DcpMutation:
+		collectionName = v.CollectionName
+	case models.DcpDeletion:
+		collectionName = v.CollectionName
+	case models.DcpExpiration:
+		collectionName = v.CollectionName
+	}
+
+	if pool, ok := s.collectionWorkerPools[collectionName]; ok {
+		return pool
+	}
+
+	return s.workerPool
+}
+
+func (s *stream) newCollectionWorkerPools() map[string]*workerPool {
+	pools := map[string]*workerPool{}
+
+	for collectionName, workers := range s.config.Dcp.Processing.CollectionWorkers {
+		pools[collectionName] = newWorkerPool(
+			"worker-"+collectionName, workers, s.config.Dcp.Listener.BufferSize, s.partitionFunc, s.goroutines,
+		)
+	}
+
+	return pools
 }
 
-func (s *stream) listen() {
-	for args := range s.observer.Listen() {
+// usesVBucketProcessors is true when the events of a vBucket can be acked out of order,
+// the checkpoint then advances only over the contiguous acks of the vBucket
+func (s *stream) usesVBucketProcessors() bool {
+	processing := s.config.Dcp.Processing
+
+	// the partition func can split a vBucket across the workers
+	return processing.PerVBucketConcurrency > 1 || processing.MaxPendingAcks > 0 || processing.DrainOnRollback ||
+		processing.Workers > 0 || len(processing.CollectionWorkers) > 0
+}
+
+func (s *stream) listen(listenerCh models.ListenerCh) {
+	tracked := s.goroutines.Start("stream-listen")
+	defer tracked.Stop()
+
+	if s.workerPool != nil {
+		defer s.workerPool.Close()
+	}
+
+	for _, pool := range s.collectionWorkerPools {
+		defer pool.Close()
+	}
+
+	if s.usesVBucketProcessors() {
+		processors := map[uint16]*vBucketProcessor{}
+
+		s.vBucketProcessorsLock.Lock()
+		s.vBucketProcessors = processors
+		s.vBucketProcessorsLock.Unlock()
+		defer s.stopVBucketProcessors(processors)
+		defer s.inFlight.Wait()
+	}
+
+	if maxPendingAcks := s.config.Dcp.Processing.MaxPendingAcks; maxPendingAcks > 0 {
+		s.pendingAcks = make(chan struct{}, maxPendingAcks)
+	}
+
+	for args := range listenerCh {
 		event := args.Event
 
 		switch v := event.(type) {
+		case models.DcpSnapshotMarker:
+			s.metric.SnapshotSize.Observe(float64(v.EndSeqNo - v.StartSeqNo))
 		case models.DcpMutation:
+			s.touchVBucket(v.VbID)
 			s.waitAndForward(v, v.Offset, v.VbID, v.EventTime)
 		case models.DcpDeletion:
+			s.touchVBucket(v.VbID)
 			s.waitAndForward(v, v.Offset, v.VbID, v.EventTime)
 		case models.DcpExpiration:
+			s.touchVBucket(v.VbID)
 			s.waitAndForward(v, v.Offset, v.VbID, v.EventTime)
 		case models.DcpSeqNoAdvanced:
-			s.setOffset(v.VbID, v.Offset, true)
+			if processor := s.getVBucketProcessor(v.VbID); processor != nil {
+				processor.tracker.Skip(v.Offset, func(offset *models.Offset) {
+					s.setOffset(v.VbID, offset, true)
+				})
+			} else {
+				s.setOffset(v.VbID, v.Offset, true)
+			}
 		default:
 		}
+
+		tracked.Touch()
 	}
 }
 
-func (s *stream) listenEnd() {
-	for range s.observer.ListenEnd() {
+// pruneOutOfRangeVBuckets drops the loaded offsets and clears the checkpoints of the vBuckets which do not exist anymore,
+// the checkpoints can not be listed so every vBucket up to the max vBucket count is cleared once per process
+func (s *stream) pruneOutOfRangeVBuckets(vBucketCount int) {
+	// file metadata loads the checkpoints of all vBuckets, they are deleted after the range which holds the lock
+	var loaded []uint16
+	s.offsets.Range(func(vbID uint16, _ *models.Offset) bool {
+		if int(vbID) >= vBucketCount {
+			loaded = append(loaded, vbID)
+		}
+		return true
+	})
+
+	for _, vbID := range loaded {
+		s.offsets.Delete(vbID)
+		s.dirtyOffsets.Delete(vbID)
+	}
+
+	if s.outOfRangePruned || vBucketCount >= _maxVBucketCount ||
+		s.config.Checkpoint.OutOfRangePolicy == OutOfRangeVBucketPolicyIgnore {
+		return
+	}
+
+	s.outOfRangePruned = true
+
+	outOfRange := make([]uint16, 0, _maxVBucketCount-vBucketCount)
+	for vbID := vBucketCount; vbID < _maxVBucketCount; vbID++ {
+		outOfRange = append(outOfRange, uint16(vbID))
+	}
+
+	if err := s.metadata.Clear(outOfRange); err != nil {
+		logger.Log.Error("error while pruning checkpoints of out of range vBuckets: %v", err)
+		s.bus.Emit(helpers.ErrorOccurredBusEventName, models.SubsystemError{
+			Subsystem: models.CheckpointSubsystem,
+			Err:       err,
+		})
+		return
+	}
+
+	logger.Log.Info("pruned checkpoints of vBuckets %d-%d beyond the vBucket count", vBucketCount, _maxVBucketCount-1)
+}
+
+func (s *stream) touchVBucket(vbID uint16) {
+	if s.idle != nil {
+		s.idle.Touch(vbID)
+	}
+}
+
+func (s *stream) listenEnd(listenerEndCh models.ListenerEndCh) {
+	tracked := s.goroutines.Start("stream-listen-end")
+	defer tracked.Stop()
+
+	for range listenerEndCh {
 		s.activeStreams--
 		if s.activeStreams == 0 {
 			s.finishStreamWithEndEventCh <- struct{}{}
 		}
+
+		tracked.Touch()
 	}
 }
 
 func (s *stream) Open() {
 	s.eventHandler.BeforeStreamStart()
 
-	vbIds := s.vBucketDiscovery.Get()
+	vbIds, err := s.vBucketDiscovery.Get()
+	if err != nil {
+		// no vBuckets are streamed until the next membership change opens the stream again
+		logger.Log.Error("cannot get vBuckets, no vBuckets are streamed, err: %v", err)
+		s.bus.Emit(helpers.ErrorOccurredBusEventName, models.SubsystemError{
+			Subsystem: models.MembershipSubsystem,
+			Err:       err,
+		})
+	}
 
 	if !s.config.RollbackMitigation.Disabled {
 		s.rollbackMitigation = couchbase.NewRollbackMitigation(s.client, s.config, vbIds, s.bus)
@@ -130,14 +544,35 @@ func (s *stream) Open() {
 
 	s.activeStreams = len(vbIds)
 
-	s.checkpoint = NewCheckpoint(s, vbIds, s.client, s.metadata, s.config)
-	s.offsets, s.dirtyOffsets, s.anyDirtyOffset = s.checkpoint.Load()
+	s.streamCtx, s.streamCancel = context.WithCancel(s.ctx)
+
+	s.checkpoint = NewCheckpoint(s, vbIds, s.client, s.metadata, s.config, s.bus)
+	s.loadCheckpoint()
+	s.pruneOutOfRangeVBuckets(s.client.GetNumVBuckets())
 	s.observer = couchbase.NewObserver(s.config, s.collectionIDs, s.bus)
 
+	s.openCatchUpTracker(vbIds)
+
+	if s.orderValidator != nil {
+		// reopened streams continue from the checkpoint
+		s.orderValidator.Reset()
+	}
+
+	if workers := s.config.Dcp.Processing.Workers; workers > 0 {
+		s.workerPool = newWorkerPool("worker", workers, s.config.Dcp.Listener.BufferSize, s.partitionFunc, s.goroutines)
+	}
+
+	s.collectionWorkerPools = s.newCollectionWorkerPools()
+
+	if s.idle != nil {
+		s.idle.Start(vbIds)
+	}
+
 	s.openAllStreams(vbIds)
 
-	go s.listenEnd()
-	go s.listen()
+	// the channels are read before the goroutines start, close replaces and clears them
+	go s.listenEnd(s.observer.ListenEnd())
+	go s.listen(s.observer.Listen())
 
 	logger.Log.Info("stream started")
 	s.eventHandler.AfterStreamStart()
@@ -199,12 +634,29 @@ func (s *stream) openAllStreams(vbIds []uint16) {
 	openWg := &sync.WaitGroup{}
 	openWg.Add(len(vbIds))
 
+	var openLimit chan struct{}
+	if s.balancing && s.config.Dcp.Processing.RebalanceConcurrency > 0 {
+		openLimit = make(chan struct{}, s.config.Dcp.Processing.RebalanceConcurrency)
+	}
+
 	for _, vbID := range vbIds {
+		if openLimit != nil {
+			openLimit <- struct{}{}
+		}
+
 		go func(innerVbId uint16) {
+			if openLimit != nil {
+				defer func() { <-openLimit }()
+			}
+
 			offset, _ := s.offsets.Load(innerVbId)
 			err := s.client.OpenStream(innerVbId, s.collectionIDs, offset, s.observer)
 			if err != nil {
 				logger.Log.Error("cannot open stream, vbID: %d, err: %v", innerVbId, err)
+				s.bus.Emit(helpers.ErrorOccurredBusEventName, models.SubsystemError{
+					Subsystem: models.StreamSubsystem,
+					Err:       err,
+				})
 				panic(err)
 			}
 
@@ -223,13 +675,18 @@ func (s *stream) closeAllStreams() error {
 
 	go func() {
 		var err error
+		var errLock sync.Mutex
 
 		var wg sync.WaitGroup
 		wg.Add(s.offsets.Count())
 		s.offsets.Range(func(vbID uint16, _ *models.Offset) bool {
 			go func(vbID uint16) {
 				defer wg.Done()
-				err = s.client.CloseStream(vbID)
+				if closeErr := s.client.CloseStream(vbID); closeErr != nil {
+					errLock.Lock()
+					err = closeErr
+					errLock.Unlock()
+				}
 			}(vbID)
 			return true
 		})
@@ -256,6 +713,44 @@ func (s *stream) wait() {
 	}
 }
 
+// Stop closes the dcp streams so no new events are received, the events in the listener keep their context until Close
+func (s *stream) Stop() {
+	if s.stopped {
+		return
+	}
+
+	s.stopped = true
+
+	s.observer.Close()
+
+	err := s.closeAllStreams()
+	if err != nil {
+		logger.Log.Error("cannot close all streams: %v", err)
+		s.bus.Emit(helpers.ErrorOccurredBusEventName, models.SubsystemError{
+			Subsystem: models.StreamSubsystem,
+			Err:       err,
+		})
+	}
+}
+
+// Drain waits for the events in the listener to return until the shutdown drain timeout
+func (s *stream) Drain() {
+	timeout := time.NewTimer(s.config.Timeouts.ShutdownDrain)
+	defer timeout.Stop()
+
+	ticker := time.NewTicker(_drainCheckInterval)
+	defer ticker.Stop()
+
+	for s.inProcess.Load() > 0 {
+		select {
+		case <-ticker.C:
+		case <-timeout.C:
+			logger.Log.Warn("%d events are still in the listener after %v", s.inProcess.Load(), s.config.Timeouts.ShutdownDrain)
+			return
+		}
+	}
+}
+
 func (s *stream) Close() {
 	s.eventHandler.BeforeStreamStop()
 
@@ -263,15 +758,19 @@ func (s *stream) Close() {
 		s.rollbackMitigation.Stop()
 	}
 
-	s.observer.Close()
+	if s.idle != nil {
+		s.idle.Stop()
+	}
 
-	if s.checkpoint != nil {
-		s.checkpoint.StopSchedule()
+	s.Stop()
+	s.stopped = false
+
+	if s.streamCancel != nil {
+		s.streamCancel()
 	}
 
-	err := s.closeAllStreams()
-	if err != nil {
-		logger.Log.Error("cannot close all streams: %v", err)
+	if s.checkpoint != nil {
+		s.checkpoint.StopSchedule()
 	}
 
 	s.finishStreamWithCloseCh <- struct{}{}
@@ -301,12 +800,17 @@ func (s *stream) GetCheckpointMetric() *CheckpointMetric {
 	return s.checkpoint.GetMetric()
 }
 
+func (s *stream) GetRateLimiter() *helpers.RateLimiter {
+	return s.rateLimiter
+}
+
 func (s *stream) UnmarkDirtyOffsets() {
 	s.anyDirtyOffset = false
 	s.dirtyOffsets = wrapper.CreateConcurrentSwissMap[uint16, bool](1024)
 }
 
-func NewStream(client couchbase.Client,
+func NewStream(ctx context.Context,
+	client couchbase.Client,
 	metadata metadata.Metadata,
 	config *config.Dcp,
 	vBucketDiscovery VBucketDiscovery,
@@ -315,8 +819,11 @@ func NewStream(client couchbase.Client,
 	stopCh chan struct{},
 	bus helpers.Bus,
 	eventHandler models.EventHandler,
+	partitionFunc models.PartitionFunc,
+	goroutines *helpers.Goroutines,
 ) Stream {
-	return &stream{
+	s := &stream{
+		ctx:                        ctx,
 		client:                     client,
 		metadata:                   metadata,
 		listener:                   listener,
@@ -328,6 +835,33 @@ func NewStream(client couchbase.Client,
 		stopCh:                     stopCh,
 		bus:                        bus,
 		eventHandler:               eventHandler,
-		metric:                     &Metric{},
+		partitionFunc:              partitionFunc,
+		metric:                     &Metric{SnapshotSize: helpers.NewHistogram(_snapshotSizeBuckets)},
+		rateLimiter:                helpers.NewRateLimiter(config.Dcp.Processing.RateLimit),
+		goroutines:                 goroutines,
+	}
+
+	if config.Dcp.Processing.DrainOnRollback || config.Dcp.Processing.MaxPendingAcks > 0 {
+		bus.Subscribe(helpers.RollbackBusEventName, s.rollbackListener)
 	}
+
+	if config.Debug {
+		if config.Dcp.Processing.PerVBucketConcurrency > 1 {
+			logger.Log.Info("event order is not validated, perVBucketConcurrency invokes the events of a vBucket concurrently")
+		} else {
+			s.orderValidator = newOrderValidator()
+			bus.Subscribe(helpers.RollbackBusEventName, s.orderValidator.rollbackListener)
+		}
+	}
+
+	if interval := config.Dcp.Listener.IdleInterval; interval > 0 {
+		s.idle = newIdleTracker(interval, s.vBucketIdle, goroutines)
+	}
+
+	if config.Dcp.Group.Membership.StartBarrier > 0 {
+		s.startBarrier = newStartBarrier(config.Dcp.Group.Membership.StartBarrier)
+		bus.Subscribe(helpers.MembershipChangedBusEventName, s.startBarrier.membershipChangedListener)
+	}
+
+	return s
 }
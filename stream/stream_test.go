@@ -0,0 +1,1324 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Trendyol/go-dcp/config"
+	"github.com/Trendyol/go-dcp/couchbase"
+	"github.com/Trendyol/go-dcp/helpers"
+	"github.com/Trendyol/go-dcp/logger"
+	"github.com/Trendyol/go-dcp/models"
+	"github.com/Trendyol/go-dcp/wrapper"
+
+	"github.com/couchbase/gocbcore/v10"
+)
+
+func newTestConfig() *config.Dcp {
+	c := &config.Dcp{
+		RollbackMitigation: config.RollbackMitigation{Disabled: true},
+		Logging:            config.Logging{Level: logger.ERROR},
+	}
+	c.ApplyDefaults()
+
+	return c
+}
+
+func newTestStream(ctx context.Context, c *config.Dcp, listener models.Listener) *stream {
+	bus := helpers.NewBus()
+
+	s := NewStream(
+		ctx, nil, nil, c, nil, listener, nil,
+		make(chan struct{}, 1), bus, models.DefaultEventHandler, models.DefaultPartitionFunc, helpers.NewGoroutines(),
+	).(*stream)
+	s.checkpoint = &checkpoint{}
+	s.observer = couchbase.NewObserver(c, nil, bus)
+	s.streamCtx, s.streamCancel = context.WithCancel(s.ctx)
+
+	return s
+}
+
+func sendMutation(observer couchbase.Observer, vbID uint16, seqNo uint64) {
+	observer.SnapshotMarker(models.DcpSnapshotMarker{VbID: vbID, StartSeqNo: seqNo, EndSeqNo: seqNo})
+	observer.Mutation(gocbcore.DcpMutation{VbID: vbID, SeqNo: seqNo, Key: []byte("key")})
+}
+
+func TestStream_ListenerContextIsCancelledWithParentContext(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+
+	received := make(chan context.Context, 1)
+
+	s := newTestStream(parent, newTestConfig(), func(ctx *models.ListenerContext) {
+		received <- ctx.Context
+	})
+
+	go s.listen(s.observer.Listen())
+
+	sendMutation(s.observer, 0, 1)
+
+	var listenerCtx context.Context
+
+	select {
+	case listenerCtx = <-received:
+	case <-time.After(time.Second):
+		t.Fatal("listener is not invoked")
+	}
+
+	if listenerCtx.Err() != nil {
+		t.Fatalf("listener context must not be done before cancel")
+	}
+
+	cancel()
+
+	select {
+	case <-listenerCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("listener context is not cancelled with parent context")
+	}
+}
+
+func TestStream_ObservesSnapshotSizes(t *testing.T) {
+	received := make(chan struct{}, 1)
+
+	s := newTestStream(context.Background(), newTestConfig(), func(ctx *models.ListenerContext) {
+		received <- struct{}{}
+	})
+
+	go s.listen(s.observer.Listen())
+
+	s.observer.SnapshotMarker(models.DcpSnapshotMarker{VbID: 0, StartSeqNo: 0, EndSeqNo: 5})
+	s.observer.SnapshotMarker(models.DcpSnapshotMarker{VbID: 1, StartSeqNo: 10, EndSeqNo: 500})
+	s.observer.SnapshotMarker(models.DcpSnapshotMarker{VbID: 2, StartSeqNo: 100, EndSeqNo: 200000})
+	sendMutation(s.observer, 3, 7)
+
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("listener is not invoked")
+	}
+
+	count, sum, buckets := s.GetMetric().SnapshotSize.Snapshot()
+
+	if count != 4 {
+		t.Errorf("count = %v, want 4", count)
+	}
+
+	if sum != 5+490+199900 {
+		t.Errorf("sum = %v, want %v", sum, 5+490+199900)
+	}
+
+	expected := map[float64]uint64{1: 1, 10: 2, 1000: 3, 100000: 3, 1000000: 4}
+	for upperBound, want := range expected {
+		if buckets[upperBound] != want {
+			t.Errorf("bucket %v = %v, want %v", upperBound, buckets[upperBound], want)
+		}
+	}
+}
+
+func TestStream_PerVBucketConcurrencyAdvancesOverContiguousAcks(t *testing.T) {
+	c := newTestConfig()
+	c.Dcp.Processing.PerVBucketConcurrency = 4
+
+	received := make(chan *models.ListenerContext, 4)
+	release := make(chan struct{})
+
+	s := newTestStream(context.Background(), c, func(ctx *models.ListenerContext) {
+		received <- ctx
+		<-release
+	})
+	s.offsets = wrapper.CreateConcurrentSwissMap[uint16, *models.Offset](1024)
+	s.dirtyOffsets = wrapper.CreateConcurrentSwissMap[uint16, bool](1024)
+
+	go s.listen(s.observer.Listen())
+
+	for seqNo := uint64(1); seqNo <= 4; seqNo++ {
+		sendMutation(s.observer, 0, seqNo)
+	}
+
+	contexts := map[uint64]*models.ListenerContext{}
+
+	for i := 0; i < 4; i++ {
+		select {
+		case ctx := <-received:
+			contexts[ctx.Event.(models.DcpMutation).SeqNo] = ctx
+		case <-time.After(time.Second):
+			t.Fatalf("only %v events are in flight, want 4", i)
+		}
+	}
+
+	close(release)
+
+	assertSeqNo := func(expected uint64) {
+		t.Helper()
+
+		offset, ok := s.offsets.Load(0)
+		if expected == 0 && ok {
+			t.Errorf("offset must not be advanced, got %v", offset.SeqNo)
+		}
+
+		if expected != 0 && (!ok || offset.SeqNo != expected) {
+			t.Errorf("offset = %v, want %v", offset, expected)
+		}
+	}
+
+	contexts[3].Ack()
+	contexts[4].Ack()
+	assertSeqNo(0)
+
+	contexts[1].Ack()
+	assertSeqNo(1)
+
+	contexts[2].Ack()
+	assertSeqNo(4)
+}
+
+func TestStream_AsyncAcksHoldCheckpointAndPauseConsuming(t *testing.T) {
+	c := newTestConfig()
+	c.Dcp.Processing.MaxPendingAcks = 2
+
+	received := make(chan *models.ListenerContext, 3)
+
+	s := newTestStream(context.Background(), c, func(ctx *models.ListenerContext) {
+		received <- ctx
+	})
+	s.offsets = wrapper.CreateConcurrentSwissMap[uint16, *models.Offset](1024)
+	s.dirtyOffsets = wrapper.CreateConcurrentSwissMap[uint16, bool](1024)
+
+	go s.listen(s.observer.Listen())
+
+	for seqNo := uint64(1); seqNo <= 3; seqNo++ {
+		sendMutation(s.observer, 0, seqNo)
+	}
+
+	receive := func() *models.ListenerContext {
+		t.Helper()
+
+		select {
+		case ctx := <-received:
+			return ctx
+		case <-time.After(time.Second):
+			t.Fatal("listener is not invoked")
+			return nil
+		}
+	}
+
+	first, second := receive(), receive()
+
+	select {
+	case <-received:
+		t.Fatal("consuming must be paused while 2 acks are pending")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if _, ok := s.offsets.Load(0); ok {
+		t.Fatal("offset must not be advanced before acks")
+	}
+
+	second.Ack()
+
+	if _, ok := s.offsets.Load(0); ok {
+		t.Fatal("offset must not be advanced before the first event is acked")
+	}
+
+	third := receive()
+
+	first.Ack()
+
+	if offset, _ := s.offsets.Load(0); offset == nil || offset.SeqNo != 2 {
+		t.Fatalf("offset = %v, want 2", offset)
+	}
+
+	third.Ack()
+
+	if offset, _ := s.offsets.Load(0); offset == nil || offset.SeqNo != 3 {
+		t.Fatalf("offset = %v, want 3", offset)
+	}
+}
+
+func TestStream_PendingAcksDoNotBlockCloseOrRollback(t *testing.T) {
+	c := newTestConfig()
+	c.Dcp.Processing.MaxPendingAcks = 1
+
+	received := make(chan uint64, 3)
+
+	// the listener never acks
+	s := newTestStream(context.Background(), c, func(ctx *models.ListenerContext) {
+		received <- ctx.Event.(models.DcpMutation).SeqNo
+	})
+	s.offsets = wrapper.CreateConcurrentSwissMap[uint16, *models.Offset](1024)
+	s.dirtyOffsets = wrapper.CreateConcurrentSwissMap[uint16, bool](1024)
+
+	listened := make(chan struct{})
+	go func() {
+		s.listen(s.observer.Listen())
+		close(listened)
+	}()
+
+	receive := func(want uint64) {
+		t.Helper()
+
+		select {
+		case seqNo := <-received:
+			if seqNo != want {
+				t.Fatalf("received seqNo %v, want %v", seqNo, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("seqNo %v is not received", want)
+		}
+	}
+
+	sendMutation(s.observer, 0, 1)
+	sendMutation(s.observer, 0, 2)
+
+	receive(1)
+
+	// redelivered events after the rollback seqNo free their slots
+	s.observer.Rollback(0, 0)
+
+	receive(2)
+
+	sendMutation(s.observer, 0, 3)
+
+	s.streamCancel()
+	s.observer.Close()
+
+	select {
+	case <-listened:
+	case <-time.After(time.Second):
+		t.Fatal("listen must return on close while acks are pending")
+	}
+}
+
+func TestStream_DrainOnRollbackQuiescesInFlightEvents(t *testing.T) {
+	c := newTestConfig()
+	c.Dcp.Processing.PerVBucketConcurrency = 2
+	c.Dcp.Processing.DrainOnRollback = true
+
+	var lock sync.Mutex
+	var written []uint64
+	var blocked int32
+
+	started := make(chan struct{}, 1)
+	completed := make(chan uint64, 3)
+
+	s := newTestStream(context.Background(), c, func(ctx *models.ListenerContext) {
+		seqNo := ctx.Event.(models.DcpMutation).SeqNo
+
+		if seqNo == 2 && atomic.CompareAndSwapInt32(&blocked, 0, 1) {
+			started <- struct{}{}
+			<-ctx.Context.Done()
+		}
+
+		if ctx.Context.Err() == nil {
+			lock.Lock()
+			written = append(written, seqNo)
+			lock.Unlock()
+		}
+
+		ctx.Ack()
+		completed <- seqNo
+	})
+	s.offsets = wrapper.CreateConcurrentSwissMap[uint16, *models.Offset](1024)
+	s.dirtyOffsets = wrapper.CreateConcurrentSwissMap[uint16, bool](1024)
+
+	go s.listen(s.observer.Listen())
+
+	sendMutation(s.observer, 0, 1)
+
+	select {
+	case <-completed:
+	case <-time.After(time.Second):
+		t.Fatal("first event is not completed")
+	}
+
+	sendMutation(s.observer, 0, 2)
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("second event is not in flight")
+	}
+
+	s.observer.Rollback(0, 1)
+
+	select {
+	case <-completed:
+	default:
+		t.Fatal("in-flight event must be completed before rollback returns")
+	}
+
+	if offset, _ := s.offsets.Load(0); offset == nil || offset.SeqNo != 1 {
+		t.Fatalf("offset = %v, want 1", offset)
+	}
+
+	// redelivered after reopening from the rollback seqNo
+	sendMutation(s.observer, 0, 2)
+
+	select {
+	case <-completed:
+	case <-time.After(time.Second):
+		t.Fatal("redelivered event is not completed")
+	}
+
+	if offset, _ := s.offsets.Load(0); offset == nil || offset.SeqNo != 2 {
+		t.Fatalf("offset = %v, want 2", offset)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(written) != 2 || written[0] != 1 || written[1] != 2 {
+		t.Errorf("written = %v, want [1 2]", written)
+	}
+}
+
+type fakeOpenStreamClient struct {
+	couchbase.Client
+	inFlight    int32
+	maxInFlight int32
+	opened      int32
+}
+
+func (c *fakeOpenStreamClient) OpenStream(_ uint16, _ map[uint32]string, _ *models.Offset, _ couchbase.Observer) error {
+	current := atomic.AddInt32(&c.inFlight, 1)
+	defer atomic.AddInt32(&c.inFlight, -1)
+
+	for {
+		previous := atomic.LoadInt32(&c.maxInFlight)
+		if current <= previous || atomic.CompareAndSwapInt32(&c.maxInFlight, previous, current) {
+			break
+		}
+	}
+
+	time.Sleep(time.Millisecond)
+	atomic.AddInt32(&c.opened, 1)
+
+	return nil
+}
+
+func TestStream_RebalanceConcurrencyLimitsStreamOpens(t *testing.T) {
+	c := newTestConfig()
+	c.Dcp.Processing.RebalanceConcurrency = 8
+
+	client := &fakeOpenStreamClient{}
+
+	s := newTestStream(context.Background(), c, func(ctx *models.ListenerContext) {})
+	s.client = client
+	s.offsets = wrapper.CreateConcurrentSwissMap[uint16, *models.Offset](1024)
+	s.balancing = true
+
+	vbIds := make([]uint16, 0, 512)
+	for i := 0; i < 512; i++ {
+		vbIds = append(vbIds, uint16(i))
+	}
+
+	s.openAllStreams(vbIds)
+
+	if client.opened != 512 {
+		t.Errorf("opened streams = %v, want 512", client.opened)
+	}
+
+	if client.maxInFlight > 8 {
+		t.Errorf("concurrent opens = %v, want at most 8", client.maxInFlight)
+	}
+}
+
+func TestStream_CollectionWorkerPoolsIsolateCollections(t *testing.T) {
+	c := newTestConfig()
+	c.Dcp.Processing.CollectionWorkers = map[string]int{"hot": 1, "quiet": 2}
+
+	release := make(chan struct{})
+	processed := make(chan string, 2)
+
+	s := newTestStream(context.Background(), c, func(ctx *models.ListenerContext) {
+		collectionName := ctx.Event.(models.DcpMutation).CollectionName
+		if collectionName == "hot" {
+			<-release
+		}
+
+		ctx.Ack()
+		processed <- collectionName
+	})
+	s.observer = couchbase.NewObserver(c, map[uint32]string{1: "hot", 2: "quiet"}, s.bus)
+	s.collectionWorkerPools = s.newCollectionWorkerPools()
+	s.offsets = wrapper.CreateConcurrentSwissMap[uint16, *models.Offset](1024)
+	s.dirtyOffsets = wrapper.CreateConcurrentSwissMap[uint16, bool](1024)
+
+	go s.listen(s.observer.Listen())
+
+	s.observer.SnapshotMarker(models.DcpSnapshotMarker{VbID: 0, StartSeqNo: 1, EndSeqNo: 2})
+	s.observer.Mutation(gocbcore.DcpMutation{VbID: 0, SeqNo: 1, CollectionID: 1, Key: []byte("hot")})
+	s.observer.Mutation(gocbcore.DcpMutation{VbID: 0, SeqNo: 2, CollectionID: 2, Key: []byte("quiet")})
+
+	select {
+	case collectionName := <-processed:
+		if collectionName != "quiet" {
+			t.Fatalf("processed = %v, want quiet", collectionName)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("slow hot collection delays the quiet one")
+	}
+
+	if offset, ok := s.offsets.Load(0); ok {
+		t.Fatalf("offset must not advance past the un-acked seqNo 1, got %v", offset.SeqNo)
+	}
+
+	close(release)
+
+	select {
+	case collectionName := <-processed:
+		if collectionName != "hot" {
+			t.Fatalf("processed = %v, want hot", collectionName)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("hot collection is not processed")
+	}
+
+	if offset, _ := s.offsets.Load(0); offset == nil || offset.SeqNo != 2 {
+		t.Fatalf("offset = %v, want 2", offset)
+	}
+}
+
+func TestStream_WorkerPoolSplittingVBucketAdvancesOverContiguousAcks(t *testing.T) {
+	c := newTestConfig()
+	c.Dcp.Processing.Workers = 2
+
+	release := make(chan struct{})
+	processed := make(chan uint64, 2)
+
+	s := newTestStream(context.Background(), c, func(ctx *models.ListenerContext) {
+		seqNo := ctx.Event.(models.DcpMutation).SeqNo
+		if seqNo == 1 {
+			<-release
+		}
+
+		ctx.Ack()
+		processed <- seqNo
+	})
+	s.workerPool = newWorkerPool("worker", 2, c.Dcp.Listener.BufferSize, func(event interface{}) int {
+		return int(event.(models.DcpMutation).SeqNo)
+	}, s.goroutines)
+	s.offsets = wrapper.CreateConcurrentSwissMap[uint16, *models.Offset](1024)
+	s.dirtyOffsets = wrapper.CreateConcurrentSwissMap[uint16, bool](1024)
+
+	go s.listen(s.observer.Listen())
+
+	sendMutation(s.observer, 0, 1)
+	sendMutation(s.observer, 0, 2)
+
+	select {
+	case seqNo := <-processed:
+		if seqNo != 2 {
+			t.Fatalf("processed = %v, want 2", seqNo)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("second worker is not processed")
+	}
+
+	if offset, ok := s.offsets.Load(0); ok {
+		t.Fatalf("offset must not advance past the un-acked seqNo 1, got %v", offset.SeqNo)
+	}
+
+	close(release)
+
+	select {
+	case <-processed:
+	case <-time.After(time.Second):
+		t.Fatal("first worker is not processed")
+	}
+
+	if offset, _ := s.offsets.Load(0); offset == nil || offset.SeqNo != 2 {
+		t.Fatalf("offset = %v, want 2", offset)
+	}
+}
+
+func TestStream_StartBarrierHoldsDispatchUntilMembershipIsStable(t *testing.T) {
+	c := newTestConfig()
+	c.Dcp.Group.Membership.StartBarrier = 100 * time.Millisecond
+
+	invoked := make(chan time.Time, 1)
+
+	s := newTestStream(context.Background(), c, func(ctx *models.ListenerContext) {
+		invoked <- time.Now()
+	})
+	s.offsets = wrapper.CreateConcurrentSwissMap[uint16, *models.Offset](1024)
+	s.dirtyOffsets = wrapper.CreateConcurrentSwissMap[uint16, bool](1024)
+
+	start := time.Now()
+
+	go s.listen(s.observer.Listen())
+
+	sendMutation(s.observer, 0, 1)
+
+	time.Sleep(50 * time.Millisecond)
+	changed := time.Now()
+	s.bus.Emit(helpers.MembershipChangedBusEventName, nil)
+
+	select {
+	case at := <-invoked:
+		if at.Sub(changed) < 100*time.Millisecond {
+			t.Fatalf("handler is invoked %v after the last membership change, want at least 100ms", at.Sub(changed))
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("handler is not invoked %v after start", time.Since(start))
+	}
+}
+
+func TestStream_ConsumerCaughtUpFiresOnceAfterBackfill(t *testing.T) {
+	acked := make(chan uint16, 8)
+
+	s := newTestStream(context.Background(), newTestConfig(), func(ctx *models.ListenerContext) {
+		ctx.Ack()
+		acked <- ctx.Event.(models.DcpMutation).VbID
+	})
+	s.client = &fakeSeqNoClient{seqNos: map[uint16]uint64{0: 3, 1: 2, 2: 5}}
+	s.offsets = wrapper.CreateConcurrentSwissMap[uint16, *models.Offset](1024)
+	s.dirtyOffsets = wrapper.CreateConcurrentSwissMap[uint16, bool](1024)
+	s.offsets.Store(1, &models.Offset{SeqNo: 1})
+	s.offsets.Store(2, &models.Offset{SeqNo: 5})
+
+	var caughtUp atomic.Int32
+	s.bus.Subscribe(helpers.ConsumerCaughtUpBusEventName, func(event interface{}) {
+		if event.(models.ConsumerCaughtUp).VBucketCount != 3 {
+			t.Errorf("VBucketCount = %v, want 3", event.(models.ConsumerCaughtUp).VBucketCount)
+		}
+		caughtUp.Add(1)
+	})
+
+	s.openCatchUpTracker([]uint16{0, 1, 2})
+
+	go s.listen(s.observer.Listen())
+
+	waitAcked := func() {
+		select {
+		case <-acked:
+		case <-time.After(time.Second):
+			t.Fatal("listener is not invoked")
+		}
+	}
+
+	for seqNo := uint64(1); seqNo <= 3; seqNo++ {
+		sendMutation(s.observer, 0, seqNo)
+		waitAcked()
+	}
+
+	if caughtUp.Load() != 0 || s.GetMetric().CaughtUp.Load() {
+		t.Fatalf("consumer must not be caught up before vBucket 1 reaches its high seqNo")
+	}
+
+	sendMutation(s.observer, 1, 2)
+	waitAcked()
+	sendMutation(s.observer, 0, 4)
+	waitAcked()
+
+	if caughtUp.Load() != 1 {
+		t.Errorf("ConsumerCaughtUp fired %v times, want 1", caughtUp.Load())
+	}
+
+	if !s.GetMetric().CaughtUp.Load() {
+		t.Errorf("CaughtUp metric is not set")
+	}
+}
+
+func TestStream_ConsumerCaughtUpKeepsStartupHighSeqNosOnReopen(t *testing.T) {
+	s := newTestStream(context.Background(), newTestConfig(), func(ctx *models.ListenerContext) {})
+	client := &fakeSeqNoClient{seqNos: map[uint16]uint64{0: 3, 1: 2}}
+	s.client = client
+	s.offsets = wrapper.CreateConcurrentSwissMap[uint16, *models.Offset](1024)
+	s.dirtyOffsets = wrapper.CreateConcurrentSwissMap[uint16, bool](1024)
+
+	var caughtUp atomic.Int32
+	s.bus.Subscribe(helpers.ConsumerCaughtUpBusEventName, func(event interface{}) {
+		caughtUp.Add(1)
+	})
+
+	s.openCatchUpTracker([]uint16{0, 1})
+
+	// the streams are reopened by a rebalance while the bucket keeps receiving writes
+	client.seqNos = map[uint16]uint64{0: 10, 1: 10}
+	s.openCatchUpTracker([]uint16{0, 1})
+
+	s.setOffset(0, &models.Offset{SeqNo: 3}, true)
+	s.setOffset(1, &models.Offset{SeqNo: 2}, true)
+
+	if caughtUp.Load() != 1 || !s.GetMetric().CaughtUp.Load() {
+		t.Errorf("ConsumerCaughtUp fired %v times, want once at the startup high seqNos", caughtUp.Load())
+	}
+}
+
+func TestOrderValidator_DetectsOutOfOrderEvents(t *testing.T) {
+	c := newTestConfig()
+	c.Debug = true
+
+	s := newTestStream(context.Background(), c, func(ctx *models.ListenerContext) {})
+
+	validator := s.orderValidator
+	if validator == nil {
+		t.Fatal("order validator must be enabled in debug mode")
+	}
+
+	for _, seqNo := range []uint64{1, 2, 5} {
+		if err := validator.Check(0, seqNo); err != nil {
+			t.Fatalf("seqNo %v: %v", seqNo, err)
+		}
+	}
+
+	if err := validator.Check(1, 3); err != nil {
+		t.Fatalf("vBuckets must be validated independently: %v", err)
+	}
+
+	if err := validator.Check(0, 4); err == nil {
+		t.Fatal("out of order seqNo 4 after 5 is not detected")
+	}
+
+	if err := validator.Check(0, 5); err == nil {
+		t.Fatal("repeated seqNo 5 is not detected")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Validate must panic on violation")
+		}
+	}()
+
+	validator.Validate(1, 2)
+}
+
+func TestOrderValidator_AllowsConcurrentCompletionOrder(t *testing.T) {
+	c := newTestConfig()
+	c.Debug = true
+	c.Dcp.Processing.PerVBucketConcurrency = 3
+
+	completed := make(chan uint64, 3)
+
+	// later events complete first
+	s := newTestStream(context.Background(), c, func(ctx *models.ListenerContext) {
+		seqNo := ctx.Event.(models.DcpMutation).SeqNo
+		time.Sleep(time.Duration(4-seqNo) * 10 * time.Millisecond)
+
+		ctx.Ack()
+		completed <- seqNo
+	})
+	if s.orderValidator != nil {
+		t.Fatal("order validator must be disabled with perVBucketConcurrency")
+	}
+
+	s.offsets = wrapper.CreateConcurrentSwissMap[uint16, *models.Offset](1024)
+	s.dirtyOffsets = wrapper.CreateConcurrentSwissMap[uint16, bool](1024)
+
+	go s.listen(s.observer.Listen())
+
+	for seqNo := uint64(1); seqNo <= 3; seqNo++ {
+		sendMutation(s.observer, 0, seqNo)
+	}
+
+	var order []uint64
+	for i := 0; i < 3; i++ {
+		select {
+		case seqNo := <-completed:
+			order = append(order, seqNo)
+		case <-time.After(time.Second):
+			t.Fatalf("completed %v, want 3 events", order)
+		}
+	}
+
+	if order[0] == 1 {
+		t.Fatalf("completed %v, want a reordering", order)
+	}
+
+	if offset, _ := s.offsets.Load(0); offset == nil || offset.SeqNo != 3 {
+		t.Errorf("offset = %v, want 3", offset)
+	}
+}
+
+func TestOrderValidator_DetectsWorkerPoolReordering(t *testing.T) {
+	c := newTestConfig()
+	c.Debug = true
+	c.Dcp.Processing.Workers = 2
+
+	invoked := make(chan uint64, 3)
+
+	// seqNo 3 is invoked by the other worker while the first worker holds seqNo 1 or 2
+	s := newTestStream(context.Background(), c, func(ctx *models.ListenerContext) {
+		seqNo := ctx.Event.(models.DcpMutation).SeqNo
+		if seqNo == 1 {
+			select {
+			case <-invoked:
+			case <-time.After(time.Second):
+			}
+		}
+
+		ctx.Ack()
+		invoked <- seqNo
+	})
+	s.workerPool = newWorkerPool("worker", 2, c.Dcp.Listener.BufferSize, func(event interface{}) int {
+		if event.(models.DcpMutation).SeqNo == 3 {
+			return 1
+		}
+		return 0
+	}, s.goroutines)
+	s.offsets = wrapper.CreateConcurrentSwissMap[uint16, *models.Offset](1024)
+	s.dirtyOffsets = wrapper.CreateConcurrentSwissMap[uint16, bool](1024)
+
+	violations := make(chan error, 3)
+	s.orderValidator.violated = func(err error) {
+		violations <- err
+	}
+
+	go s.listen(s.observer.Listen())
+
+	for seqNo := uint64(1); seqNo <= 3; seqNo++ {
+		sendMutation(s.observer, 0, seqNo)
+	}
+
+	select {
+	case err := <-violations:
+		if !strings.Contains(err.Error(), "is received after seqNo: 3") {
+			t.Errorf("violation = %v, want an earlier seqNo after 3", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("worker pool reordering is not detected")
+	}
+}
+
+func TestOrderValidator_RollbackRestartsVBucket(t *testing.T) {
+	c := newTestConfig()
+	c.Debug = true
+
+	s := newTestStream(context.Background(), c, func(ctx *models.ListenerContext) {})
+
+	_ = s.orderValidator.Check(0, 10)
+	s.bus.Emit(helpers.RollbackBusEventName, models.Rollback{VbID: 0, SeqNo: 5})
+
+	if err := s.orderValidator.Check(0, 6); err != nil {
+		t.Errorf("seqNo after rollback must be accepted: %v", err)
+	}
+}
+
+func TestStream_ErrRetryAfterRedeliversEventAfterDelay(t *testing.T) {
+	invoked := make(chan time.Time, 2)
+
+	var attempts atomic.Int32
+
+	s := newTestStream(context.Background(), newTestConfig(), func(ctx *models.ListenerContext) {
+		invoked <- time.Now()
+
+		if attempts.Add(1) == 1 {
+			ctx.Err = models.ErrRetryAfter{Duration: 100 * time.Millisecond}
+			return
+		}
+
+		ctx.Ack()
+	})
+	s.offsets = wrapper.CreateConcurrentSwissMap[uint16, *models.Offset](1024)
+	s.dirtyOffsets = wrapper.CreateConcurrentSwissMap[uint16, bool](1024)
+
+	go s.listen(s.observer.Listen())
+
+	sendMutation(s.observer, 0, 1)
+
+	receive := func() time.Time {
+		select {
+		case at := <-invoked:
+			return at
+		case <-time.After(time.Second):
+			t.Fatal("listener is not invoked")
+			return time.Time{}
+		}
+	}
+
+	first := receive()
+
+	time.Sleep(50 * time.Millisecond)
+
+	if dirty, _ := s.dirtyOffsets.Load(0); dirty {
+		t.Fatal("checkpoint must hold until the event is redelivered and acked")
+	}
+
+	second := receive()
+
+	if second.Sub(first) < 100*time.Millisecond {
+		t.Errorf("event is redelivered after %v, want at least 100ms", second.Sub(first))
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	if dirty, _ := s.dirtyOffsets.Load(0); !dirty {
+		t.Error("checkpoint must advance after the redelivered event is acked")
+	}
+}
+
+func TestStream_FanOutListenerAckPolicy(t *testing.T) {
+	for policy, wantDirty := range map[string]bool{config.FanOutAckPolicyAll: false, config.FanOutAckPolicyAny: true} {
+		indexed := make(chan struct{}, 1)
+
+		search := func(ctx *models.ListenerContext) {
+			ctx.Ack()
+			indexed <- struct{}{}
+		}
+		// cache sink fails and never acks
+		cache := func(ctx *models.ListenerContext) {}
+
+		processed := make(chan struct{}, 1)
+
+		fanOut := NewFanOutListener(policy, search, cache)
+
+		s := newTestStream(context.Background(), newTestConfig(), func(ctx *models.ListenerContext) {
+			fanOut(ctx)
+			processed <- struct{}{}
+		})
+		s.offsets = wrapper.CreateConcurrentSwissMap[uint16, *models.Offset](1024)
+		s.dirtyOffsets = wrapper.CreateConcurrentSwissMap[uint16, bool](1024)
+
+		go s.listen(s.observer.Listen())
+
+		sendMutation(s.observer, 0, 1)
+
+		for _, ch := range []chan struct{}{indexed, processed} {
+			select {
+			case <-ch:
+			case <-time.After(time.Second):
+				t.Fatalf("policy %v: listener is not invoked", policy)
+			}
+		}
+
+		if dirty, _ := s.dirtyOffsets.Load(0); dirty != wantDirty {
+			t.Errorf("policy %v: checkpoint advanced = %v, want %v", policy, dirty, wantDirty)
+		}
+
+		s.streamCancel()
+	}
+}
+
+func TestFanOutListener_FastListenerDoesNotWaitForSlowListener(t *testing.T) {
+	release := make(chan struct{})
+	var slow []uint64
+	var lock sync.Mutex
+
+	search := func(ctx *models.ListenerContext) {
+		ctx.Ack()
+	}
+	cache := func(ctx *models.ListenerContext) {
+		<-release
+
+		lock.Lock()
+		slow = append(slow, ctx.Event.(uint64))
+		lock.Unlock()
+	}
+
+	fanOut := NewFanOutListener(config.FanOutAckPolicyAny, search, cache)
+
+	acked := 0
+	for seqNo := uint64(1); seqNo <= 3; seqNo++ {
+		returned := make(chan struct{})
+
+		go func(seqNo uint64) {
+			fanOut(&models.ListenerContext{Context: context.Background(), Event: seqNo, Ack: func() { acked++ }})
+			close(returned)
+		}(seqNo)
+
+		select {
+		case <-returned:
+		case <-time.After(time.Second):
+			t.Fatalf("event %v waits for the slow listener", seqNo)
+		}
+	}
+
+	if acked != 3 {
+		t.Errorf("acked = %v, want 3", acked)
+	}
+
+	close(release)
+
+	for i := 0; i < 100; i++ {
+		lock.Lock()
+		n := len(slow)
+		lock.Unlock()
+
+		if n == 3 {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(slow) != 3 || slow[0] != 1 || slow[1] != 2 || slow[2] != 3 {
+		t.Errorf("slow listener received %v, want [1 2 3] in order", slow)
+	}
+}
+
+func TestFanOutListener_ErrRetryAfterRedeliversOnlyToTheFailedListener(t *testing.T) {
+	var searched, cached int32
+
+	search := func(ctx *models.ListenerContext) {
+		atomic.AddInt32(&searched, 1)
+		ctx.Ack()
+	}
+	cache := func(ctx *models.ListenerContext) {
+		if atomic.AddInt32(&cached, 1) == 1 {
+			ctx.Err = models.ErrRetryAfter{Duration: time.Millisecond}
+			return
+		}
+		ctx.Ack()
+	}
+	failed := errors.New("cache is down")
+	broken := func(ctx *models.ListenerContext) {
+		ctx.Err = failed
+	}
+
+	acked := false
+	ctx := &models.ListenerContext{Context: context.Background(), Ack: func() { acked = true }}
+
+	NewFanOutListener(config.FanOutAckPolicyAll, search, cache)(ctx)
+
+	if searched != 1 || cached != 2 {
+		t.Errorf("search is invoked %v times and cache %v times, want 1 and 2", searched, cached)
+	}
+
+	if !acked || ctx.Err != nil {
+		t.Errorf("acked = %v, err = %v, want acked without error", acked, ctx.Err)
+	}
+
+	ctx = &models.ListenerContext{Context: context.Background(), Ack: func() {}}
+
+	NewFanOutListener(config.FanOutAckPolicyAll, search, broken)(ctx)
+
+	if !errors.Is(ctx.Err, failed) {
+		t.Errorf("err = %v, want the error of the failed listener", ctx.Err)
+	}
+}
+
+func TestStream_DrainWaitsForListenerUntilShutdownDrainTimeout(t *testing.T) {
+	c := newTestConfig()
+	c.Timeouts.ShutdownDrain = 100 * time.Millisecond
+
+	release := make(chan struct{})
+	invoked := make(chan struct{}, 1)
+
+	s := newTestStream(context.Background(), c, func(ctx *models.ListenerContext) {
+		invoked <- struct{}{}
+		<-release
+	})
+	s.offsets = wrapper.CreateConcurrentSwissMap[uint16, *models.Offset](1024)
+	s.dirtyOffsets = wrapper.CreateConcurrentSwissMap[uint16, bool](1024)
+
+	go s.listen(s.observer.Listen())
+
+	sendMutation(s.observer, 0, 1)
+	<-invoked
+
+	start := time.Now()
+	s.Drain()
+
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("drain returned after %v, want the shutdown drain timeout", elapsed)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+
+	start = time.Now()
+	s.Drain()
+
+	if elapsed := time.Since(start); elapsed >= 100*time.Millisecond {
+		t.Errorf("drain returned after %v, want as soon as the listener returns", elapsed)
+	}
+}
+
+func TestStream_StopKeepsInFlightEventsUntilTheyAreDrained(t *testing.T) {
+	release := make(chan struct{})
+	invoked := make(chan uint64, 2)
+	returned := make(chan error, 2)
+
+	s := newTestStream(context.Background(), newTestConfig(), func(ctx *models.ListenerContext) {
+		invoked <- ctx.Event.(models.DcpMutation).SeqNo
+		<-release
+		returned <- ctx.Context.Err()
+	})
+	s.offsets = wrapper.CreateConcurrentSwissMap[uint16, *models.Offset](1024)
+	s.dirtyOffsets = wrapper.CreateConcurrentSwissMap[uint16, bool](1024)
+
+	go s.listen(s.observer.Listen())
+
+	sendMutation(s.observer, 0, 1)
+	<-invoked
+
+	s.Stop()
+
+	// received after stop
+	sendMutation(s.observer, 0, 2)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+
+	s.Drain()
+
+	if err := <-returned; err != nil {
+		t.Errorf("in-flight event context err = %v, want it alive while draining", err)
+	}
+
+	select {
+	case seqNo := <-invoked:
+		t.Errorf("seqNo %v is dispatched after stop", seqNo)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestStream_IdleVBucketIsReportedAtInterval(t *testing.T) {
+	c := newTestConfig()
+	s := newTestStream(context.Background(), c, func(ctx *models.ListenerContext) {})
+	s.offsets = wrapper.CreateConcurrentSwissMap[uint16, *models.Offset](1024)
+	s.offsets.Store(0, &models.Offset{SeqNo: 7})
+	s.offsets.Store(1, &models.Offset{SeqNo: 9})
+
+	var lock sync.Mutex
+	idle := map[uint16][]models.VBucketIdle{}
+	var idleTimes []time.Time
+
+	s.bus.Subscribe(helpers.VBucketIdleBusEventName, func(event interface{}) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		vBucketIdle := event.(models.VBucketIdle)
+		idle[vBucketIdle.VbID] = append(idle[vBucketIdle.VbID], vBucketIdle)
+
+		if vBucketIdle.VbID == 0 {
+			idleTimes = append(idleTimes, time.Now())
+		}
+	})
+
+	interval := 50 * time.Millisecond
+
+	s.idle = newIdleTracker(interval, s.vBucketIdle, s.goroutines)
+	s.idle.Start([]uint16{0, 1})
+
+	// vBucket 1 keeps receiving events while vBucket 0 is idle
+	for deadline := time.Now().Add(5*interval + interval/2); time.Now().Before(deadline); {
+		s.touchVBucket(1)
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	registered := false
+	for _, goroutine := range s.goroutines.Get() {
+		registered = registered || (goroutine.Name == "stream-idle" && goroutine.State == helpers.GoroutineRunning)
+	}
+
+	if !registered {
+		t.Error("idle tracker goroutine is not registered")
+	}
+
+	s.idle.Stop()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(idle[1]) != 0 {
+		t.Errorf("active vBucket is reported idle %v times", len(idle[1]))
+	}
+
+	if n := len(idle[0]); n < 4 || n > 5 {
+		t.Fatalf("idle vBucket is reported %v times in 5 intervals, want about once per interval", n)
+	}
+
+	if idle[0][0].SeqNo != 7 {
+		t.Errorf("seqNo = %v, want 7", idle[0][0].SeqNo)
+	}
+
+	for i := 1; i < len(idleTimes); i++ {
+		if gap := idleTimes[i].Sub(idleTimes[i-1]); gap < interval-interval/_idleCheckDivisor {
+			t.Errorf("idle reports are %v apart, want about %v", gap, interval)
+		}
+	}
+}
+
+type fakeShrunkBucketClient struct {
+	fakeOpenStreamClient
+	vBucketCount int
+}
+
+func (c *fakeShrunkBucketClient) GetNumVBuckets() int {
+	return c.vBucketCount
+}
+
+func (c *fakeShrunkBucketClient) GetBucketUUID() (string, error) {
+	return "uuid", nil
+}
+
+func (c *fakeShrunkBucketClient) GetVBucketSeqNos() (map[uint16]uint64, error) {
+	return map[uint16]uint64{}, nil
+}
+
+func (c *fakeShrunkBucketClient) CloseStream(_ uint16) error {
+	return nil
+}
+
+type fakeVBucketDiscovery struct {
+	VBucketDiscovery
+	vbIds []uint16
+}
+
+func (d *fakeVBucketDiscovery) Get() ([]uint16, error) {
+	return d.vbIds, nil
+}
+
+func TestStream_ShrunkVBucketCountPrunesOutOfRangeCheckpoints(t *testing.T) {
+	c := newTestConfig()
+
+	// checkpointed while the bucket had 1024 vBuckets, file metadata loads all of them
+	metadata := &fakeMetadata{docs: map[uint16]*models.CheckpointDocument{}}
+	for _, vbID := range getVBuckets(1024) {
+		metadata.docs[vbID] = newCheckpointDocument(10)
+	}
+
+	client := &fakeShrunkBucketClient{vBucketCount: 64}
+
+	s := NewStream(
+		context.Background(), client, metadata, c, &fakeVBucketDiscovery{vbIds: getVBuckets(64)}, func(ctx *models.ListenerContext) {}, nil,
+		make(chan struct{}, 1), helpers.NewBus(), models.DefaultEventHandler, models.DefaultPartitionFunc, helpers.NewGoroutines(),
+	).(*stream)
+	s.balancing = true
+
+	s.Open()
+
+	if n := s.offsets.Count(); n != 64 {
+		t.Errorf("offsets = %v, want 64", n)
+	}
+
+	s.offsets.Range(func(vbID uint16, _ *models.Offset) bool {
+		if vbID >= 64 {
+			t.Errorf("offset of out of range vBucket %v is kept", vbID)
+		}
+		return true
+	})
+
+	if client.opened != 64 {
+		t.Errorf("opened streams = %v, want 64", client.opened)
+	}
+
+	s.Close()
+	s.Open()
+	defer s.Close()
+
+	cleared := map[uint16]bool{}
+	for _, vbID := range metadata.cleared {
+		if vbID < 64 {
+			t.Errorf("checkpoint of valid vBucket %v is cleared", vbID)
+		}
+		cleared[vbID] = true
+	}
+
+	if len(cleared) != 960 || len(metadata.cleared) != 960 {
+		t.Errorf("cleared %v checkpoints (%v unique), want 960 once", len(metadata.cleared), len(cleared))
+	}
+}
+
+type slowLoadMetadata struct {
+	fakeMetadata
+	delay time.Duration
+}
+
+func (m *slowLoadMetadata) Load(vbIds []uint16, bucketUUID string) (*wrapper.ConcurrentSwissMap[uint16, *models.CheckpointDocument], bool, error) {
+	time.Sleep(m.delay)
+	return m.fakeMetadata.Load(vbIds, bucketUUID)
+}
+
+func TestStream_StartupCheckpointLoadSecondsIsKeptOnReopen(t *testing.T) {
+	c := newTestConfig()
+	metadata := &slowLoadMetadata{delay: 50 * time.Millisecond}
+	client := &fakeShrunkBucketClient{vBucketCount: 1024}
+
+	s := newTestStream(context.Background(), c, func(ctx *models.ListenerContext) {})
+	s.checkpoint = NewCheckpoint(s, []uint16{0}, client, metadata, c, s.bus)
+
+	s.loadCheckpoint()
+
+	startup := s.GetMetric().StartupCheckpointLoadSeconds
+	if startup < metadata.delay.Seconds() {
+		t.Fatalf("startup checkpoint load = %vs, want at least %vs", startup, metadata.delay.Seconds())
+	}
+
+	// the rebalance loads the checkpoint again without the delay
+	metadata.delay = 0
+	s.checkpoint = NewCheckpoint(s, []uint16{0}, client, metadata, c, s.bus)
+
+	s.loadCheckpoint()
+
+	if loadSeconds := s.GetMetric().StartupCheckpointLoadSeconds; loadSeconds != startup {
+		t.Errorf("startup checkpoint load = %vs after reopen, want %vs", loadSeconds, startup)
+	}
+}
+
+type fakeRollbackClient struct {
+	fakeShrunkBucketClient
+	rollback atomic.Bool
+}
+
+func (c *fakeRollbackClient) OpenStream(vbID uint16, collectionIDs map[uint32]string, offset *models.Offset, observer couchbase.Observer) error {
+	if c.rollback.Load() {
+		observer.Rollback(vbID, 0)
+	}
+
+	return c.fakeShrunkBucketClient.OpenStream(vbID, collectionIDs, offset, observer)
+}
+
+func TestStream_DrainOnRollbackWaitsForEventsOfPreviousStreamsOnReopen(t *testing.T) {
+	c := newTestConfig()
+	c.Dcp.Processing.PerVBucketConcurrency = 2
+	c.Dcp.Processing.DrainOnRollback = true
+
+	release := make(chan struct{})
+	invoked := make(chan struct{}, 1)
+
+	// the listener does not return on cancel
+	listener := func(ctx *models.ListenerContext) {
+		invoked <- struct{}{}
+		<-release
+		ctx.Ack()
+	}
+
+	client := &fakeRollbackClient{fakeShrunkBucketClient: fakeShrunkBucketClient{vBucketCount: 1024}}
+
+	s := NewStream(
+		context.Background(), client, &fakeMetadata{}, c, &fakeVBucketDiscovery{vbIds: []uint16{0}}, listener, nil,
+		make(chan struct{}, 1), helpers.NewBus(), models.DefaultEventHandler, models.DefaultPartitionFunc, helpers.NewGoroutines(),
+	).(*stream)
+	s.balancing = true
+
+	s.Open()
+
+	sendMutation(s.observer, 0, 5)
+	<-invoked
+
+	s.Close()
+
+	client.rollback.Store(true)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+
+	start := time.Now()
+	s.Open()
+	defer s.Close()
+
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("reopen returned after %v, want after the in-flight event of the rolled back vBucket", elapsed)
+	}
+
+	if offset, ok := s.offsets.Load(0); ok && offset.SeqNo == 5 {
+		t.Error("ack of the rolled back event must not advance the reloaded checkpoint")
+	}
+}
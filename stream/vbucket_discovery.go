@@ -1,7 +1,10 @@
 package stream
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/Trendyol/go-dcp/config"
 
@@ -15,15 +18,53 @@ import (
 )
 
 type VBucketDiscovery interface {
-	Get() []uint16
+	Get() ([]uint16, error)
 	Close()
 	GetMetric() *VBucketDiscoveryMetric
+	GetMembership() membership.Membership
+	GetAssignment() []MemberAssignment
+	Explain() *AssignmentExplanation
 }
 
+// AssignmentStrategyContiguousChunk splits the ordered vBuckets into a contiguous chunk per member
+const AssignmentStrategyContiguousChunk = "contiguousChunk"
+
+const (
+	// _infoAttempts bounds how many times the membership info is waited for
+	_infoAttempts = 3
+	// _maxInfoTimeout caps the doubled wait for the membership info unless the info timeout is longer
+	_maxInfoTimeout = time.Minute
+)
+
+var errVBucketDiscoveryClosed = errors.New("vbucket discovery is closed")
+
 type vBucketDiscovery struct {
 	membership             membership.Membership
 	vBucketDiscoveryMetric *VBucketDiscoveryMetric
+	ctx                    context.Context
+	cancel                 context.CancelFunc
+	vBuckets               []uint16
 	vBucketNumber          int
+	infoTimeout            time.Duration
+}
+
+type MemberAssignment struct {
+	ID                string `json:"id,omitempty"`
+	MemberNumber      int    `json:"memberNumber"`
+	VBucketCount      int    `json:"vBucketCount"`
+	VBucketRangeStart uint16 `json:"vBucketRangeStart"`
+	VBucketRangeEnd   uint16 `json:"vBucketRangeEnd"`
+}
+
+type AssignmentExplanation struct {
+	Type              string   `json:"type"`
+	Strategy          string   `json:"strategy"`
+	VBuckets          []uint16 `json:"vBuckets"`
+	MemberNumber      int      `json:"memberNumber"`
+	TotalMembers      int      `json:"totalMembers"`
+	VBucketCount      int      `json:"vBucketCount"`
+	VBucketRangeStart uint16   `json:"vBucketRangeStart"`
+	VBucketRangeEnd   uint16   `json:"vBucketRangeEnd"`
 }
 
 type VBucketDiscoveryMetric struct {
@@ -35,14 +76,84 @@ type VBucketDiscoveryMetric struct {
 	VBucketRangeEnd   uint16
 }
 
-func (s *vBucketDiscovery) Get() []uint16 {
-	vBuckets := make([]uint16, 0, s.vBucketNumber)
+func getVBuckets(vBucketNumber int) []uint16 {
+	vBuckets := make([]uint16, 0, vBucketNumber)
 
-	for i := 0; i < s.vBucketNumber; i++ {
+	for i := 0; i < vBucketNumber; i++ {
 		vBuckets = append(vBuckets, uint16(i))
 	}
 
-	receivedInfo := s.membership.GetInfo()
+	return vBuckets
+}
+
+func getAssignment(vBucketNumber int, totalMembers int) []MemberAssignment {
+	if totalMembers == 0 {
+		return []MemberAssignment{}
+	}
+
+	chunks := helpers.ChunkSlice[uint16](getVBuckets(vBucketNumber), totalMembers)
+	assignment := make([]MemberAssignment, 0, len(chunks))
+
+	for i, chunk := range chunks {
+		memberAssignment := MemberAssignment{
+			MemberNumber: i + 1,
+			VBucketCount: len(chunk),
+		}
+
+		if len(chunk) > 0 {
+			memberAssignment.VBucketRangeStart = chunk[0]
+			memberAssignment.VBucketRangeEnd = chunk[len(chunk)-1]
+		}
+
+		assignment = append(assignment, memberAssignment)
+	}
+
+	return assignment
+}
+
+// getInfo waits for the membership info again with a doubled timeout after each timeout,
+// it returns an error after _infoAttempts timeouts or when the discovery is closed
+func (s *vBucketDiscovery) getInfo() (*membership.Model, error) {
+	timeout := s.infoTimeout
+	maxTimeout := _maxInfoTimeout
+	if s.infoTimeout > maxTimeout {
+		maxTimeout = s.infoTimeout
+	}
+
+	var err error
+
+	for attempt := 1; attempt <= _infoAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(s.ctx, timeout)
+		var info *membership.Model
+		info, err = s.membership.GetInfoContext(ctx)
+		cancel()
+
+		if err == nil {
+			return info, nil
+		}
+
+		if s.ctx.Err() != nil {
+			return nil, errVBucketDiscoveryClosed
+		}
+
+		logger.Log.Warn("cannot get membership info in %v, attempt: %v/%v, err: %v", timeout, attempt, _infoAttempts, err)
+
+		timeout *= 2
+		if timeout > maxTimeout {
+			timeout = maxTimeout
+		}
+	}
+
+	return nil, fmt.Errorf("cannot get membership info after %v attempts: %w", _infoAttempts, err)
+}
+
+func (s *vBucketDiscovery) Get() ([]uint16, error) {
+	vBuckets := getVBuckets(s.vBucketNumber)
+
+	receivedInfo, err := s.getInfo()
+	if err != nil {
+		return nil, err
+	}
 
 	readyToStreamVBuckets := helpers.ChunkSlice[uint16](vBuckets, receivedInfo.TotalMembers)[receivedInfo.MemberNumber-1]
 
@@ -57,11 +168,13 @@ func (s *vBucketDiscovery) Get() []uint16 {
 	s.vBucketDiscoveryMetric.MemberNumber = receivedInfo.MemberNumber
 	s.vBucketDiscoveryMetric.VBucketRangeStart = start
 	s.vBucketDiscoveryMetric.VBucketRangeEnd = end
+	s.vBuckets = readyToStreamVBuckets
 
-	return readyToStreamVBuckets
+	return readyToStreamVBuckets, nil
 }
 
 func (s *vBucketDiscovery) Close() {
+	s.cancel()
 	s.membership.Close()
 	logger.Log.Debug("vbucket discovery closed")
 }
@@ -70,10 +183,45 @@ func (s *vBucketDiscovery) GetMetric() *VBucketDiscoveryMetric {
 	return s.vBucketDiscoveryMetric
 }
 
+func (s *vBucketDiscovery) GetMembership() membership.Membership {
+	return s.membership
+}
+
+func (s *vBucketDiscovery) Explain() *AssignmentExplanation {
+	return &AssignmentExplanation{
+		Type:              s.vBucketDiscoveryMetric.Type,
+		Strategy:          AssignmentStrategyContiguousChunk,
+		MemberNumber:      s.vBucketDiscoveryMetric.MemberNumber,
+		TotalMembers:      s.vBucketDiscoveryMetric.TotalMembers,
+		VBucketCount:      s.vBucketNumber,
+		VBucketRangeStart: s.vBucketDiscoveryMetric.VBucketRangeStart,
+		VBucketRangeEnd:   s.vBucketDiscoveryMetric.VBucketRangeEnd,
+		VBuckets:          s.vBuckets,
+	}
+}
+
+// GetAssignment returns the vBuckets of every member for the latest received membership
+func (s *vBucketDiscovery) GetAssignment() []MemberAssignment {
+	assignment := getAssignment(s.vBucketNumber, s.vBucketDiscoveryMetric.TotalMembers)
+
+	if clusterMembership, ok := s.membership.(couchbase.ClusterMembership); ok {
+		instances := clusterMembership.GetInstances()
+
+		if len(instances) == len(assignment) {
+			for i := range assignment {
+				assignment[i].ID = *instances[i].ID
+			}
+		}
+	}
+
+	return assignment
+}
+
 func NewVBucketDiscovery(client couchbase.Client,
 	config *config.Dcp,
 	vBucketNumber int,
 	bus helpers.Bus,
+	goroutines *helpers.Goroutines,
 ) VBucketDiscovery {
 	var ms membership.Membership
 
@@ -81,7 +229,7 @@ func NewVBucketDiscovery(client couchbase.Client,
 	case config.Dcp.Group.Membership.Type == membership.StaticMembershipType:
 		ms = membership.NewStaticMembership(config)
 	case config.Dcp.Group.Membership.Type == membership.CouchbaseMembershipType:
-		ms = couchbase.NewCBMembership(config, client, bus)
+		ms = couchbase.NewCBMembership(config, client, bus, goroutines)
 	case config.Dcp.Group.Membership.Type == membership.KubernetesStatefulSetMembershipType:
 		ms = kubernetes.NewStatefulSetMembership(config)
 	case config.Dcp.Group.Membership.Type == membership.KubernetesHaMembershipType:
@@ -94,9 +242,14 @@ func NewVBucketDiscovery(client couchbase.Client,
 
 	logger.Log.Debug("vbucket discovery opened with membership type: %s", config.Dcp.Group.Membership.Type)
 
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &vBucketDiscovery{
 		vBucketNumber: vBucketNumber,
 		membership:    ms,
+		ctx:           ctx,
+		cancel:        cancel,
+		infoTimeout:   config.Dcp.Group.Membership.InfoTimeout,
 		vBucketDiscoveryMetric: &VBucketDiscoveryMetric{
 			VBucketCount: vBucketNumber,
 			Type:         config.Dcp.Group.Membership.Type,
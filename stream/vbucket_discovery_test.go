@@ -0,0 +1,193 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Trendyol/go-dcp/logger"
+	"github.com/Trendyol/go-dcp/membership"
+)
+
+type fakeSlowMembership struct {
+	membership.Membership
+	model    *membership.Model
+	failures int
+}
+
+func (m *fakeSlowMembership) GetInfoContext(ctx context.Context) (*membership.Model, error) {
+	if m.failures > 0 {
+		m.failures--
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	return m.model, nil
+}
+
+func (m *fakeSlowMembership) Close() {}
+
+func TestVBucketDiscovery_GetAssignmentCoversAllVBuckets(t *testing.T) {
+	c := newTestConfig()
+	c.Dcp.Group.Membership.MemberNumber = 2
+	c.Dcp.Group.Membership.TotalMembers = 3
+
+	discovery := &vBucketDiscovery{
+		membership:             membership.NewStaticMembership(c),
+		vBucketNumber:          1024,
+		ctx:                    context.Background(),
+		infoTimeout:            c.Dcp.Group.Membership.InfoTimeout,
+		vBucketDiscoveryMetric: &VBucketDiscoveryMetric{VBucketCount: 1024},
+	}
+
+	vBuckets, err := discovery.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assignment := discovery.GetAssignment()
+
+	if len(assignment) != 3 {
+		t.Fatalf("assignment has %v members, want 3", len(assignment))
+	}
+
+	total := 0
+	for i, memberAssignment := range assignment {
+		if memberAssignment.MemberNumber != i+1 {
+			t.Errorf("member number = %v, want %v", memberAssignment.MemberNumber, i+1)
+		}
+
+		total += memberAssignment.VBucketCount
+	}
+
+	if total != 1024 {
+		t.Errorf("assigned vBucket count = %v, want 1024", total)
+	}
+
+	self := assignment[1]
+	if self.VBucketCount != len(vBuckets) || self.VBucketRangeStart != vBuckets[0] || self.VBucketRangeEnd != vBuckets[len(vBuckets)-1] {
+		t.Errorf("self assignment = %+v, vBuckets = %v-%v", self, vBuckets[0], vBuckets[len(vBuckets)-1])
+	}
+}
+
+func TestVBucketDiscovery_ExplainMatchesAssignment(t *testing.T) {
+	c := newTestConfig()
+	c.Dcp.Group.Membership.Type = membership.StaticMembershipType
+	c.Dcp.Group.Membership.MemberNumber = 3
+	c.Dcp.Group.Membership.TotalMembers = 4
+
+	discovery := &vBucketDiscovery{
+		membership:    membership.NewStaticMembership(c),
+		vBucketNumber: 1024,
+		ctx:           context.Background(),
+		infoTimeout:   c.Dcp.Group.Membership.InfoTimeout,
+		vBucketDiscoveryMetric: &VBucketDiscoveryMetric{
+			VBucketCount: 1024,
+			Type:         c.Dcp.Group.Membership.Type,
+		},
+	}
+
+	vBuckets, err := discovery.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	explanation := discovery.Explain()
+
+	if explanation.Type != membership.StaticMembershipType || explanation.Strategy != AssignmentStrategyContiguousChunk {
+		t.Errorf("type = %v, strategy = %v", explanation.Type, explanation.Strategy)
+	}
+
+	if explanation.MemberNumber != 3 || explanation.TotalMembers != 4 || explanation.VBucketCount != 1024 {
+		t.Errorf("explanation = %+v", explanation)
+	}
+
+	if explanation.VBucketRangeStart != 512 || explanation.VBucketRangeEnd != 767 {
+		t.Errorf("range = %v-%v, want 512-767", explanation.VBucketRangeStart, explanation.VBucketRangeEnd)
+	}
+
+	if len(explanation.VBuckets) != len(vBuckets) || explanation.VBuckets[0] != vBuckets[0] {
+		t.Errorf("vBuckets = %v, want %v", explanation.VBuckets, vBuckets)
+	}
+
+	self := discovery.GetAssignment()[explanation.MemberNumber-1]
+	if self.VBucketRangeStart != explanation.VBucketRangeStart || self.VBucketRangeEnd != explanation.VBucketRangeEnd {
+		t.Errorf("assignment = %+v does not match explanation", self)
+	}
+}
+
+func TestVBucketDiscovery_GetWaitsAgainWhenMembershipInfoTimesOut(t *testing.T) {
+	logger.InitDefaultLogger(logger.ERROR)
+
+	discovery := &vBucketDiscovery{
+		membership:             &fakeSlowMembership{model: &membership.Model{MemberNumber: 1, TotalMembers: 2}, failures: _infoAttempts - 1},
+		ctx:                    context.Background(),
+		vBucketNumber:          1024,
+		infoTimeout:            10 * time.Millisecond,
+		vBucketDiscoveryMetric: &VBucketDiscoveryMetric{VBucketCount: 1024},
+	}
+
+	vBuckets, err := discovery.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(vBuckets) != 512 || vBuckets[0] != 0 {
+		t.Errorf("vBuckets = %v, want the first half", len(vBuckets))
+	}
+}
+
+func TestVBucketDiscovery_GetReturnsErrorAfterMembershipInfoAttempts(t *testing.T) {
+	logger.InitDefaultLogger(logger.ERROR)
+
+	discovery := &vBucketDiscovery{
+		membership:             &fakeSlowMembership{failures: _infoAttempts},
+		ctx:                    context.Background(),
+		vBucketNumber:          1024,
+		infoTimeout:            10 * time.Millisecond,
+		vBucketDiscoveryMetric: &VBucketDiscoveryMetric{VBucketCount: 1024},
+	}
+
+	vBuckets, err := discovery.Get()
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the membership info timeout", err)
+	}
+
+	if len(vBuckets) != 0 {
+		t.Errorf("vBuckets = %v, want none", len(vBuckets))
+	}
+}
+
+func TestVBucketDiscovery_CloseInterruptsMembershipInfoWait(t *testing.T) {
+	logger.InitDefaultLogger(logger.ERROR)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	discovery := &vBucketDiscovery{
+		membership:             &fakeSlowMembership{failures: _infoAttempts},
+		ctx:                    ctx,
+		cancel:                 cancel,
+		vBucketNumber:          1024,
+		infoTimeout:            time.Hour,
+		vBucketDiscoveryMetric: &VBucketDiscoveryMetric{VBucketCount: 1024},
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := discovery.Get()
+		errCh <- err
+	}()
+
+	discovery.Close()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, errVBucketDiscoveryClosed) {
+			t.Errorf("err = %v, want %v", err, errVBucketDiscoveryClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("close does not interrupt the membership info wait")
+	}
+}
@@ -0,0 +1,170 @@
+package stream
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Trendyol/go-dcp/helpers"
+	"github.com/Trendyol/go-dcp/models"
+)
+
+type pendingOffset struct {
+	offset    *models.Offset
+	acked     bool
+	discarded bool
+	holdsSlot bool
+}
+
+// ackTracker keeps offsets in dispatch order so the checkpoint only advances over contiguous acks
+type ackTracker struct {
+	pending []*pendingOffset
+	lock    sync.Mutex
+}
+
+// Track holdsSlot marks that the offset holds a pending ack slot until it is acked or released
+func (t *ackTracker) Track(offset *models.Offset, holdsSlot bool) *pendingOffset {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	p := &pendingOffset{offset: offset, holdsSlot: holdsSlot}
+	t.pending = append(t.pending, p)
+
+	return p
+}
+
+// Ack returns true if the pending ack slot of the offset is freed
+func (t *ackTracker) Ack(p *pendingOffset, advance func(offset *models.Offset)) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if p.acked {
+		return false
+	}
+
+	p.acked = true
+
+	freed := p.holdsSlot
+	p.holdsSlot = false
+
+	if p.discarded {
+		return freed
+	}
+
+	var last *models.Offset
+
+	i := 0
+	for ; i < len(t.pending) && t.pending[i].acked; i++ {
+		last = t.pending[i].offset
+	}
+
+	t.pending = t.pending[i:]
+
+	if last != nil {
+		advance(last)
+	}
+
+	return freed
+}
+
+// Skip advances over an offset which is not forwarded to the listener
+func (t *ackTracker) Skip(offset *models.Offset, advance func(offset *models.Offset)) {
+	t.Ack(t.Track(offset, false), advance)
+}
+
+// Discard drops the pending offsets after the seqNo, their acks will not advance the checkpoint anymore,
+// it returns the count of the pending ack slots freed by them
+func (t *ackTracker) Discard(seqNo uint64) int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	pending := t.pending[:0]
+	freed := 0
+
+	for _, p := range t.pending {
+		if p.offset.SeqNo > seqNo {
+			p.discarded = true
+			if p.holdsSlot {
+				p.holdsSlot = false
+				freed++
+			}
+		} else {
+			pending = append(pending, p)
+		}
+	}
+
+	t.pending = pending
+
+	return freed
+}
+
+// FreeSlots frees the pending ack slots of all offsets which are not acked yet, it returns their count
+func (t *ackTracker) FreeSlots() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	freed := 0
+
+	for _, p := range t.pending {
+		if p.holdsSlot {
+			p.holdsSlot = false
+			freed++
+		}
+	}
+
+	return freed
+}
+
+type vBucketProcessor struct {
+	ctx         context.Context
+	tracker     *ackTracker
+	semaphore   chan struct{}
+	pendingAcks chan struct{}
+	cancel      context.CancelFunc
+	tracked     *helpers.Goroutine
+	inFlight    sync.WaitGroup
+}
+
+// Track blocks until a pending ack slot is free, it returns false if the vBucket is closed or drained meanwhile
+func (p *vBucketProcessor) Track(offset *models.Offset) (*pendingOffset, bool) {
+	if p.pendingAcks != nil {
+		select {
+		case p.pendingAcks <- struct{}{}:
+		case <-p.ctx.Done():
+			return nil, false
+		}
+	}
+
+	return p.tracker.Track(offset, p.pendingAcks != nil), true
+}
+
+func (p *vBucketProcessor) freeSlots(count int) {
+	for ; count > 0; count-- {
+		<-p.pendingAcks
+	}
+}
+
+// Drain cancels the in-flight events of the vBucket and waits until they return
+func (p *vBucketProcessor) Drain() {
+	p.cancel()
+	p.inFlight.Wait()
+	p.Stop()
+}
+
+// Stop frees the pending ack slots of the events which are not acked, their late acks do not free them again
+func (p *vBucketProcessor) Stop() {
+	p.freeSlots(p.tracker.FreeSlots())
+	p.tracked.Stop()
+}
+
+func newVBucketProcessor(ctx context.Context, concurrency int, pendingAcks chan struct{}, tracked *helpers.Goroutine) *vBucketProcessor {
+	ctx, cancel := context.WithCancel(ctx)
+
+	return &vBucketProcessor{
+		ctx:         ctx,
+		cancel:      cancel,
+		tracked:     tracked,
+		tracker:     &ackTracker{},
+		semaphore:   make(chan struct{}, concurrency),
+		pendingAcks: pendingAcks,
+	}
+}
@@ -0,0 +1,62 @@
+package stream
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Trendyol/go-dcp/helpers"
+	"github.com/Trendyol/go-dcp/models"
+)
+
+type workerPool struct {
+	partitionFunc models.PartitionFunc
+	queues        []chan func()
+	wg            sync.WaitGroup
+}
+
+func (p *workerPool) work(queue chan func(), tracked *helpers.Goroutine) {
+	defer p.wg.Done()
+	defer tracked.Stop()
+
+	for task := range queue {
+		task()
+		tracked.Touch()
+	}
+}
+
+func (p *workerPool) partition(event interface{}) int {
+	partition := p.partitionFunc(event) % len(p.queues)
+	if partition < 0 {
+		partition += len(p.queues)
+	}
+
+	return partition
+}
+
+func (p *workerPool) Dispatch(event interface{}, task func()) {
+	p.queues[p.partition(event)] <- task
+}
+
+func (p *workerPool) Close() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+
+	p.wg.Wait()
+}
+
+func newWorkerPool(name string, size int, queueSize uint, partitionFunc models.PartitionFunc, goroutines *helpers.Goroutines) *workerPool {
+	pool := &workerPool{
+		partitionFunc: partitionFunc,
+		queues:        make([]chan func(), size),
+	}
+
+	pool.wg.Add(size)
+
+	for i := range pool.queues {
+		pool.queues[i] = make(chan func(), queueSize)
+		go pool.work(pool.queues[i], goroutines.Start(fmt.Sprintf("%s-%d", name, i)))
+	}
+
+	return pool
+}
@@ -0,0 +1,86 @@
+package stream
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/Trendyol/go-dcp/helpers"
+	"github.com/Trendyol/go-dcp/models"
+)
+
+type partitionedEvent struct {
+	key   int
+	order int
+}
+
+func TestWorkerPool_RoutesEventsByPartitionFunc(t *testing.T) {
+	pool := newWorkerPool("worker", 4, 10, func(event interface{}) int {
+		return event.(partitionedEvent).key
+	}, nil)
+	defer pool.Close()
+
+	for key, expected := range map[int]int{0: 0, 1: 1, 5: 1, 7: 3, -1: 3} {
+		if partition := pool.partition(partitionedEvent{key: key}); partition != expected {
+			t.Errorf("key %v routed to worker %v, want %v", key, partition, expected)
+		}
+	}
+}
+
+func TestWorkerPool_KeepsOrderWithinPartition(t *testing.T) {
+	pool := newWorkerPool("worker", 3, 10, func(event interface{}) int {
+		return event.(partitionedEvent).key
+	}, nil)
+
+	var lock sync.Mutex
+	processed := map[int][]int{}
+
+	for order := 0; order < 100; order++ {
+		event := partitionedEvent{key: order % 5, order: order}
+
+		pool.Dispatch(event, func() {
+			lock.Lock()
+			defer lock.Unlock()
+
+			processed[event.key] = append(processed[event.key], event.order)
+		})
+	}
+
+	pool.Close()
+
+	for key, orders := range processed {
+		if len(orders) != 20 {
+			t.Errorf("partition %v processed %v events, want 20", key, len(orders))
+		}
+
+		for i := 1; i < len(orders); i++ {
+			if orders[i-1] > orders[i] {
+				t.Fatalf("partition %v processed out of order: %v", key, orders)
+			}
+		}
+	}
+}
+
+func TestWorkerPool_RegistersWorkersUntilClose(t *testing.T) {
+	goroutines := helpers.NewGoroutines()
+
+	pool := newWorkerPool("worker", 2, 10, models.DefaultPartitionFunc, goroutines)
+
+	states := goroutines.Get()
+	if len(states) != 2 || states[0].Name != "worker-0" || states[1].Name != "worker-1" {
+		t.Fatalf("goroutines = %v, want worker-0 and worker-1", states)
+	}
+
+	for _, state := range states {
+		if state.State != helpers.GoroutineRunning {
+			t.Errorf("%v is %v, want running", state.Name, state.State)
+		}
+	}
+
+	pool.Close()
+
+	for _, state := range goroutines.Get() {
+		if state.State != helpers.GoroutineStopped {
+			t.Errorf("%v is %v after close, want stopped", state.Name, state.State)
+		}
+	}
+}